@@ -13,10 +13,19 @@ import (
 
 	"github.com/go-redis/redis"
 	"github.com/gorilla/websocket"
+	"github.com/yourusername/payment-monitor/internal/alerting"
 	"github.com/yourusername/payment-monitor/internal/contextbuilder"
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/internal/history"
+	"github.com/yourusername/payment-monitor/internal/leader"
 	"github.com/yourusername/payment-monitor/internal/llm"
 	"github.com/yourusername/payment-monitor/internal/observer"
+	"github.com/yourusername/payment-monitor/internal/outcome"
+	"github.com/yourusername/payment-monitor/internal/replay"
+	"github.com/yourusername/payment-monitor/internal/rollup"
+	"github.com/yourusername/payment-monitor/internal/rules"
 	"github.com/yourusername/payment-monitor/internal/seeder"
+	"github.com/yourusername/payment-monitor/internal/slo"
 	wshandler "github.com/yourusername/payment-monitor/internal/websocket"
 	"github.com/yourusername/payment-monitor/pkg/config"
 	"github.com/yourusername/payment-monitor/pkg/models"
@@ -33,6 +42,12 @@ var upgrader = websocket.Upgrader{
 func main() {
 	// Load configuration
 	configPath := flag.String("config", "config/config.yaml", "path to config file")
+	mode := flag.String("mode", "serve", "serve, backfill to build the payment rollup for -from..-to, or replay to backtest detection over -from..-to")
+	fromFlag := flag.String("from", "", "start of the range to backfill or replay (RFC3339)")
+	toFlag := flag.String("to", "", "end of the range to backfill or replay (RFC3339, defaults to now)")
+	stepFlag := flag.Duration("step", time.Minute, "simulated interval between replayed checks")
+	incidentsFlag := flag.String("incidents", "", "JSON file of known incidents to measure replayed detection against")
+	reportFlag := flag.String("report", "", "file to write the replay report to (defaults to stdout)")
 	flag.Parse()
 
 	cfg, err := config.LoadConfig(*configPath)
@@ -46,6 +61,164 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Resolve monitored dimensions before starting anything
+	dimensions, err := dimension.Build(cfg.Monitoring.Dimensions)
+	if err != nil {
+		log.Fatalf("Invalid dimension config: %v", err)
+	}
+
+	outcomes, err := outcomeTaxonomy(cfg.Monitoring.Outcomes.Rules, cfg.Monitoring.Outcomes.Default)
+	if err != nil {
+		log.Fatalf("Invalid outcomes config: %v", err)
+	}
+
+	var trackerConfig *slo.Config
+	if cfg.Monitoring.SLO.Enabled {
+		if trackerConfig, err = sloConfig(cfg); err != nil {
+			log.Fatalf("Invalid slo config: %v", err)
+		}
+	}
+
+	// The rollup also groups by the dimensions of the objectives, which the
+	// SLO tracker reads from it
+	rolledUp := append([]*dimension.Dimension{}, dimensions...)
+	if trackerConfig != nil {
+		for _, objective := range trackerConfig.Objectives {
+			rolledUp = append(rolledUp, objective.Dimension)
+		}
+	}
+	paymentRollup := rollup.New(db, &rollup.Config{
+		Lateness: time.Duration(cfg.Monitoring.Rollup.LatenessMinutes) * time.Minute,
+		Outcomes: outcomes,
+	}, rolledUp)
+
+	switch *mode {
+	case "serve", "replay":
+	case "backfill":
+		runBackfill(paymentRollup, *fromFlag, *toFlag)
+		return
+	default:
+		log.Fatalf("Unknown mode: %s", *mode)
+	}
+	if *mode == "replay" {
+		paymentRollup = paymentRollup.Replay()
+	}
+
+	baseline, err := observer.ParseBaselineStrategy(cfg.Monitoring.Baseline.Strategy)
+	if err != nil {
+		log.Fatalf("Invalid baseline config: %v", err)
+	}
+
+	thresholdBasis, err := observer.ParseThresholdBasis(cfg.Monitoring.Thresholds.Basis)
+	if err != nil {
+		log.Fatalf("Invalid thresholds config: %v", err)
+	}
+
+	significance, err := observer.ParseSignificanceMethod(cfg.Monitoring.Significance.Method)
+	if err != nil {
+		log.Fatalf("Invalid significance config: %v", err)
+	}
+	if f := cfg.Monitoring.Baseline.SuccessRateFloor; f < 0 || f > 100 {
+		log.Fatalf("Invalid baseline config: success_rate_floor must be in [0, 100], got %v", f)
+	}
+	if c := cfg.Monitoring.Significance.Confidence; c < 0 || c >= 1 {
+		log.Fatalf("Invalid significance config: confidence must be in [0, 1), got %v", c)
+	}
+
+	detector, err := observer.ParseDetectorType(cfg.Monitoring.Detector.Type)
+	if err != nil {
+		log.Fatalf("Invalid detector config: %v", err)
+	}
+	forecastConfig, err := forecastConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid detector config: %v", err)
+	}
+
+	switch p := cfg.Monitoring.Latency.Percentile; p {
+	case 0, 50, 95, 99:
+	default:
+		log.Fatalf("Invalid latency config: percentile must be 50, 95 or 99, got %d", p)
+	}
+
+	// Initialize components
+	observerConfig := &observer.Config{
+		Interval:         time.Duration(cfg.Monitoring.Interval) * time.Second,
+		Threshold:        cfg.Monitoring.Thresholds.SuccessRateDrop,
+		ThresholdBasis:   thresholdBasis,
+		LostGMVThreshold: cfg.Monitoring.Thresholds.LostGMV,
+		FallbackWindow:   time.Duration(cfg.Monitoring.Baseline.FallbackHours) * time.Hour,
+		SuccessRateFloor: cfg.Monitoring.Baseline.SuccessRateFloor,
+		MinTransactions:  cfg.Monitoring.Thresholds.MinTransactions,
+		Dimensions:       dimensions,
+		Outcomes:         outcomes,
+		MaxMetricValues:  cfg.Monitoring.Metrics.MaxValuesPerDimension,
+		CurrentWindow:    time.Duration(cfg.Monitoring.Windows.CurrentMinutes) * time.Minute,
+		BaselineWindow:   time.Duration(cfg.Monitoring.Windows.BaselineMinutes) * time.Minute,
+		Baseline:         baseline,
+		BaselineWeeks:    cfg.Monitoring.Baseline.Weeks,
+		Significance:     significance,
+		Confidence:       cfg.Monitoring.Significance.Confidence,
+		DrillDownTopN:    cfg.Monitoring.DrillDown.TopN,
+		ErrorsTopN:       cfg.Monitoring.Errors.TopN,
+
+		VolumeDetection:     cfg.Monitoring.Volume.Enabled,
+		VolumeDropThreshold: cfg.Monitoring.Volume.DropPercentage,
+		MinBaselineVolume:   cfg.Monitoring.Volume.MinimumTransactions,
+
+		LatencyDetection:   cfg.Monitoring.Latency.Enabled,
+		LatencyInterval:    time.Duration(cfg.Monitoring.Latency.IntervalSeconds) * time.Second,
+		LatencyPercentile:  cfg.Monitoring.Latency.Percentile,
+		LatencyIncrease:    cfg.Monitoring.Latency.IncreasePercentage,
+		MinLatencyIncrease: time.Duration(cfg.Monitoring.Latency.MinimumIncreaseSeconds * float64(time.Second)),
+
+		RefundDetection:     cfg.Monitoring.Refunds.Enabled,
+		RefundInterval:      time.Duration(cfg.Monitoring.Refunds.IntervalSeconds) * time.Second,
+		RefundRateIncrease:  cfg.Monitoring.Refunds.RefundRateIncrease,
+		DisputeRateIncrease: cfg.Monitoring.Refunds.DisputeRateIncrease,
+		MinCapturedVolume:   cfg.Monitoring.Refunds.MinimumTransactions,
+
+		Detector: detector,
+		Forecast: forecastConfig,
+	}
+	if observerConfig.ThresholdRules, err = thresholdRules(cfg.Monitoring.Thresholds.Overrides, dimensions); err != nil {
+		log.Fatalf("Invalid thresholds config: %v", err)
+	}
+	if observerConfig.Rules, err = alertRules(cfg.Monitoring.Rules); err != nil {
+		log.Fatalf("Invalid rules config: %v", err)
+	}
+	if cfg.Monitoring.Refunds.Enabled {
+		names := cfg.Monitoring.Refunds.Dimensions
+		if len(names) == 0 {
+			names = []string{"gateway", "merchant_id"}
+		}
+		if observerConfig.RefundDimensions, err = namedDimensions(names, cfg.Monitoring.Dimensions); err != nil {
+			log.Fatalf("Invalid refunds config: %v", err)
+		}
+	}
+	var sloTracker *slo.Tracker
+	if trackerConfig != nil {
+		sloTracker = slo.NewTracker(db, trackerConfig, paymentRollup)
+		observerConfig.SLO = sloTracker
+	}
+	if cfg.Monitoring.DrillDown.Enabled {
+		for _, column := range cfg.Monitoring.DrillDown.Children {
+			field, err := dimension.ColumnField(column)
+			if err != nil {
+				log.Fatalf("Invalid drill-down config: %v", err)
+			}
+			observerConfig.DrillDown = append(observerConfig.DrillDown, field)
+		}
+	}
+
+	if *mode == "replay" {
+		runReplay(db, observerConfig, paymentRollup, &alerting.Config{
+			Cooldown:         time.Duration(cfg.Monitoring.Alerting.CooldownMinutes) * time.Minute,
+			RenotifyInterval: time.Duration(cfg.Monitoring.Alerting.RenotifyMinutes) * time.Minute,
+			Expiry:           time.Duration(cfg.Monitoring.Alerting.ExpireMinutes) * time.Minute,
+		}, *fromFlag, *toFlag, *stepFlag, *incidentsFlag, *reportFlag)
+		return
+	}
+
 	contextBuilderConfig := &contextbuilder.Config{
 		GitHubToken:   cfg.ContextBuilder.GitHub.Token,
 		GitHubRepos:   cfg.ContextBuilder.GitHub.Repos,
@@ -57,7 +230,9 @@ func main() {
 		ExperimentIds: cfg.ContextBuilder.Experiments.ExperimentIds,
 	}
 
-	contextBuilder := contextbuilder.NewContextBuilder(contextBuilderConfig, initRedis(cfg))
+	redisClient := initRedis(cfg)
+
+	contextBuilder := contextbuilder.NewContextBuilder(contextBuilderConfig, redisClient)
 
 	contextBuilder.FetchAndStorePreviousData(cfg.ContextBuilder.Experiments.ExperimentIds)
 
@@ -68,15 +243,37 @@ func main() {
 	hub := wshandler.NewHub()
 	go hub.Run()
 
-	// Initialize components
-	observerConfig := &observer.Config{
-		Interval:        time.Duration(cfg.Monitoring.Interval) * time.Second,
-		Threshold:       cfg.Monitoring.Thresholds.SuccessRateDrop,
-		MinTransactions: cfg.Monitoring.Thresholds.MinTransactions,
-		Dimensions:      getEnabledDimensions(cfg),
+	alertStore := history.NewStore(db)
+
+	alertingConfig := &alerting.Config{
+		Cooldown:         time.Duration(cfg.Monitoring.Alerting.CooldownMinutes) * time.Minute,
+		RenotifyInterval: time.Duration(cfg.Monitoring.Alerting.RenotifyMinutes) * time.Minute,
+		Expiry:           time.Duration(cfg.Monitoring.Alerting.ExpireMinutes) * time.Minute,
+	}
+	alertManager := alerting.NewManager(alertingConfig, alertStore)
+	if err := alertManager.Restore(); err != nil {
+		log.Printf("Error restoring open alerts: %v", err)
 	}
 
-	obs := observer.NewObserver(db, observerConfig, alertChannel, hub)
+	// Only the leader observes when several replicas run
+	var elector *leader.Elector
+	if cfg.LeaderElection.Enabled {
+		elector = leader.NewElector(redisClient, &leader.Config{
+			Key: cfg.LeaderElection.Key,
+			TTL: time.Duration(cfg.LeaderElection.TTLSeconds) * time.Second,
+		})
+		elector.OnElected(func() {
+			if err := alertManager.Restore(); err != nil {
+				log.Printf("Error restoring open alerts: %v", err)
+			}
+		})
+		observerConfig.Leader = elector
+		alertingConfig.Leader = elector
+		// Followers' dashboards get the leader's metrics and alerts too
+		hub.UseRelay(redisClient, cfg.LeaderElection.Key+":broadcasts")
+	}
+
+	obs := observer.NewObserver(db, observerConfig, alertChannel, hub, alertManager, paymentRollup)
 
 	// Initialize seeder
 	seed := seeder.NewSeeder(db)
@@ -84,6 +281,11 @@ func main() {
 	// Create HTTP server mux
 	mux := http.NewServeMux()
 	seed.RegisterRoutes(mux)
+	alertManager.RegisterRoutes(mux)
+	alertStore.RegisterRoutes(mux)
+	if sloTracker != nil {
+		sloTracker.RegisterRoutes(mux)
+	}
 
 	// Add WebSocket handler
 	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
@@ -126,8 +328,12 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if elector != nil {
+		go elector.Run(ctx)
+		go hub.RunRelay(ctx)
+	}
 	go obs.Start(ctx)
-	go processAlerts(ctx, alertChannel, contextBuilder, analyzer, hub)
+	go processAlerts(ctx, alertChannel, contextBuilder, analyzer, alertStore, alertManager, hub)
 
 	// Start HTTP server in background
 	go func() {
@@ -142,6 +348,12 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
+	// Hand over leadership before shutting down
+	cancel()
+	if elector != nil {
+		elector.Release()
+	}
+
 	// Shutdown server
 	log.Println("Shutting down server...")
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -167,24 +379,284 @@ func initDB(cfg *config.Config) (*gorm.DB, error) {
 	}
 
 	// Auto-migrate models
-	if err := db.AutoMigrate(&models.Payment{}); err != nil {
+	if err := db.AutoMigrate(
+		&models.Payment{},
+		&models.AlertRecord{},
+		&models.AlertAnalysis{},
+		&models.PaymentStatsMinutely{},
+		&models.RollupWatermark{},
+		&models.ForecastState{},
+	); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+	if err := dimension.Migrate(db); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
+	go createIndexes(db)
 
 	return db, nil
 }
 
-func getEnabledDimensions(cfg *config.Config) []string {
-	var dimensions []string
-	for _, dim := range cfg.Monitoring.Dimensions {
-		if dim.Enabled {
-			dimensions = append(dimensions, dim.Name)
+// paymentIndexes are built concurrently rather than by AutoMigrate, so that
+// a replica starting against a large payments table never blocks writes to
+// it. A build that fails leaves an INVALID index behind, which has to be
+// dropped by hand for the next start to build it again.
+var paymentIndexes = []string{
+	"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_payments_created_at ON payments (created_at)",
+}
+
+// createIndexes builds the missing payments indexes in the background;
+// queries only run slower until they are ready
+func createIndexes(db *gorm.DB) {
+	for _, index := range paymentIndexes {
+		if err := db.Exec(index).Error; err != nil {
+			log.Printf("Error creating index: %v", err)
 		}
 	}
-	return dimensions
 }
 
-func processAlerts(ctx context.Context, alertChan chan *models.Alert, contextBuilder *contextbuilder.ContextBuilder, analyzer *llm.Analyzer, hub *wshandler.Hub) {
+// thresholdRules converts the threshold overrides from config, checking that
+// each names an enabled dimension and has valid patterns
+func thresholdRules(overrides []config.ThresholdOverride, dimensions []*dimension.Dimension) ([]observer.ThresholdRule, error) {
+	enabled := make(map[string]bool)
+	for _, dim := range dimensions {
+		enabled[dim.Name] = true
+	}
+
+	rules := make([]observer.ThresholdRule, 0, len(overrides))
+	for i, override := range overrides {
+		rule := observer.ThresholdRule{
+			Name:            override.Name,
+			Dimension:       override.Dimension,
+			Match:           override.Match,
+			Threshold:       override.SuccessRateDrop,
+			MinTransactions: override.MinTransactions,
+			LostGMV:         override.LostGMV,
+		}
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("overrides[%d]", i)
+		}
+		if rule.Dimension != "" && !enabled[rule.Dimension] {
+			return nil, fmt.Errorf("threshold rule %s: dimension %q is not enabled", rule.Name, rule.Dimension)
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// alertRules compiles the alert rules from config, checking that their names
+// are unique
+func alertRules(configs []config.AlertRuleConfig) ([]*rules.Rule, error) {
+	var compiled []*rules.Rule
+	seen := make(map[string]bool)
+	for _, c := range configs {
+		if seen[c.Name] {
+			return nil, fmt.Errorf("alert rule %q is declared more than once", c.Name)
+		}
+		seen[c.Name] = true
+
+		rule, err := rules.New(c.Name, c.Expr, c.Severity, c.Labels)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// namedDimensions resolves dimensions checked outside the monitored set, e.g.
+// for refunds or SLOs. Names of configured dimensions keep their columns,
+// whether or not they are enabled; other names are built-in dimensions or
+// single columns.
+func namedDimensions(names []string, configured []config.DimensionConfig) ([]*dimension.Dimension, error) {
+	dimensionConfigs := make([]config.DimensionConfig, 0, len(names))
+	for _, name := range names {
+		dimensionConfig := config.DimensionConfig{Name: name}
+		for _, c := range configured {
+			if c.Name == name {
+				dimensionConfig = c
+			}
+		}
+		dimensionConfig.Enabled = true
+		dimensionConfigs = append(dimensionConfigs, dimensionConfig)
+	}
+	return dimension.Build(dimensionConfigs)
+}
+
+// sloConfig builds the objectives and burn windows from config. Objectives
+// default to a 30 day window and burn windows to the fast and slow pair.
+func sloConfig(cfg *config.Config) (*slo.Config, error) {
+	sloConfig := &slo.Config{
+		MinTransactions: cfg.Monitoring.SLO.MinimumTransactions,
+		Interval:        time.Duration(cfg.Monitoring.SLO.IntervalSeconds) * time.Second,
+	}
+	for _, w := range cfg.Monitoring.SLO.BurnWindows {
+		if w.Name == "" || w.LongMinutes <= 0 || w.ShortMinutes <= 0 || w.ShortMinutes > w.LongMinutes || w.Factor <= 0 {
+			return nil, fmt.Errorf("burn window %q needs a name, a long window no shorter than the short one and a positive factor", w.Name)
+		}
+		sloConfig.BurnWindows = append(sloConfig.BurnWindows, slo.BurnWindow{
+			Name:   w.Name,
+			Long:   time.Duration(w.LongMinutes) * time.Minute,
+			Short:  time.Duration(w.ShortMinutes) * time.Minute,
+			Factor: w.Factor,
+		})
+	}
+
+	seen := make(map[string]bool)
+	for _, o := range cfg.Monitoring.SLO.Objectives {
+		if seen[o.Name] {
+			return nil, fmt.Errorf("objective %q is declared more than once", o.Name)
+		}
+		seen[o.Name] = true
+
+		if o.Dimension == "" {
+			return nil, fmt.Errorf("objective %s: dimension is required", o.Name)
+		}
+		dimensions, err := namedDimensions([]string{o.Dimension}, cfg.Monitoring.Dimensions)
+		if err != nil {
+			return nil, fmt.Errorf("objective %s: %v", o.Name, err)
+		}
+		objective := &slo.Objective{
+			Name:      o.Name,
+			Dimension: dimensions[0],
+			Match:     o.Match,
+			Target:    o.Target,
+			Window:    time.Duration(o.WindowDays) * 24 * time.Hour,
+			Basis:     slo.Basis(o.Basis),
+		}
+		if objective.Window == 0 {
+			objective.Window = 30 * 24 * time.Hour
+		}
+		if objective.Basis == "" {
+			objective.Basis = slo.BasisCount
+		}
+		if err := objective.Validate(); err != nil {
+			return nil, err
+		}
+		sloConfig.Objectives = append(sloConfig.Objectives, objective)
+	}
+	return sloConfig, nil
+}
+
+// forecastConfig converts the forecast detector settings from config. Unset
+// settings keep the observer defaults.
+func forecastConfig(cfg *config.Config) (observer.ForecastConfig, error) {
+	c := cfg.Monitoring.Detector.Forecast
+	forecast := observer.ForecastConfig{
+		Band:              c.Band,
+		WarmUp:            c.WarmUpObservations,
+		LevelSmoothing:    c.LevelSmoothing,
+		SeasonalSmoothing: c.SeasonalSmoothing,
+	}
+	if c.Band < 0 || c.WarmUpObservations < 0 {
+		return forecast, fmt.Errorf("band and warm_up_observations must not be negative")
+	}
+	if c.LevelSmoothing < 0 || c.LevelSmoothing > 1 || c.SeasonalSmoothing < 0 || c.SeasonalSmoothing > 1 {
+		return forecast, fmt.Errorf("smoothing factors must be in [0, 1]")
+	}
+	if c.Timezone != "" {
+		location, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return forecast, fmt.Errorf("timezone: %v", err)
+		}
+		forecast.Location = location
+	}
+	return forecast, nil
+}
+
+// outcomeTaxonomy builds the payment outcome classification from config.
+// Without rules the default taxonomy applies.
+func outcomeTaxonomy(rules []config.OutcomeRule, fallback string) (*outcome.Taxonomy, error) {
+	if len(rules) == 0 && fallback == "" {
+		return outcome.Default(), nil
+	}
+	converted := make([]outcome.Rule, 0, len(rules))
+	for _, rule := range rules {
+		converted = append(converted, outcome.Rule{
+			Outcome:  outcome.Outcome(rule.Outcome),
+			Statuses: rule.Statuses,
+			Codes:    rule.Codes,
+			Reasons:  rule.Reasons,
+		})
+	}
+	return outcome.New(converted, outcome.Outcome(fallback))
+}
+
+// runBackfill builds the payment rollup for the given range of creation times
+func runBackfill(paymentRollup *rollup.Rollup, from, to string) {
+	if from == "" {
+		log.Fatalf("Backfill requires -from")
+	}
+	start, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	end := time.Now()
+	if to != "" {
+		if end, err = time.Parse(time.RFC3339, to); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+	}
+
+	if err := paymentRollup.Backfill(start, end); err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+	log.Printf("Backfill complete")
+}
+
+// runReplay backtests the configured detection over the given range and
+// writes the alerts it would have raised, matched against known incidents
+func runReplay(db *gorm.DB, observerConfig *observer.Config, paymentRollup *rollup.Rollup, alertingConfig *alerting.Config, from, to string, step time.Duration, incidentsFile, reportFile string) {
+	if from == "" {
+		log.Fatalf("Replay requires -from")
+	}
+	start, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	end := time.Now()
+	if to != "" {
+		if end, err = time.Parse(time.RFC3339, to); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+	}
+
+	var incidents []replay.Incident
+	if incidentsFile != "" {
+		if incidents, err = replay.LoadIncidents(incidentsFile); err != nil {
+			log.Fatalf("Invalid incidents: %v", err)
+		}
+	}
+
+	// A store-less manager keeps the replay away from live incidents
+	alertChannel := make(chan *models.Alert, 100)
+	var notifications []*models.Alert
+	done := make(chan struct{})
+	go func() {
+		for alert := range alertChannel {
+			notifications = append(notifications, alert)
+		}
+		close(done)
+	}()
+
+	obs := observer.NewObserver(db, observerConfig, alertChannel, nil, alerting.NewManager(alertingConfig, nil), paymentRollup)
+	if err := obs.Replay(start, end, step); err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+	close(alertChannel)
+	<-done
+
+	report := replay.NewReport(start, end, step, notifications, incidents)
+	if err := report.Write(reportFile); err != nil {
+		log.Fatalf("Error writing replay report: %v", err)
+	}
+	log.Printf("Replay complete: %d alerts, %d of %d incidents detected", report.Summary.Alerts, report.Summary.Detected, report.Summary.Incidents)
+}
+
+func processAlerts(ctx context.Context, alertChan chan *models.Alert, contextBuilder *contextbuilder.ContextBuilder, analyzer *llm.Analyzer, store *history.Store, alerts *alerting.Manager, hub *wshandler.Hub) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -194,6 +666,15 @@ func processAlerts(ctx context.Context, alertChan chan *models.Alert, contextBui
 				continue
 			}
 
+			// Resolutions only need to reach the dashboard
+			if alert.State == models.AlertStateResolved {
+				log.Printf("Alert %s resolved: %s - %s", alert.ID, alert.Dimension, alert.Attributes)
+				if hub != nil {
+					hub.BroadcastAlert(newAlertMessage(alert))
+				}
+				continue
+			}
+
 			// Build context for the alert
 			alertContext, err := contextBuilder.BuildContext(context.TODO(), alert)
 			if err != nil {
@@ -208,9 +689,13 @@ func processAlerts(ctx context.Context, alertChan chan *models.Alert, contextBui
 				continue
 			}
 
+			if err := store.SaveAnalysis(alert.ID, alertContext, analysis); err != nil {
+				log.Printf("Error saving analysis: %v", err)
+			}
+
 			// Print detailed analysis results
 			log.Printf("===== ANALYSIS RESULTS =====")
-			log.Printf("Alert for: %s - %s", alert.Dimension, alert.Value)
+			log.Printf("Alert for: %s - %s (%s)", alert.Dimension, alert.Attributes, alert.Type)
 			log.Printf("Root Cause: %s", analysis.RootCause)
 			log.Printf("Confidence: %.2f", analysis.Confidence)
 			log.Printf("Recommendations:")
@@ -229,30 +714,68 @@ func processAlerts(ctx context.Context, alertChan chan *models.Alert, contextBui
 			alert.RootCause = analysis.RootCause
 			alert.Confidence = analysis.Confidence
 			alert.Recommendations = analysis.Recommendations
+			alerts.Annotate(alert)
 
 			// Broadcast alert to WebSocket clients
 			if hub != nil {
 				// Create a properly formatted alert message
-				alertMsg := &wshandler.AlertMessage{
-					Type:            "alert",
-					ID:              alert.ID,
-					Dimension:       alert.Dimension,
-					Value:           alert.Value,
-					CurrentRate:     alert.CurrentRate,
-					PreviousRate:    alert.PreviousRate,
-					DropPercentage:  alert.DropPercentage,
-					Timestamp:       alert.Timestamp,
-					RootCause:       analysis.RootCause,
-					Confidence:      analysis.Confidence,
-					Recommendations: analysis.Recommendations,
-					RelatedChanges:  analysis.RelatedChanges,
-				}
+				alertMsg := newAlertMessage(alert)
+				alertMsg.RelatedChanges = analysis.RelatedChanges
 				hub.BroadcastAlert(alertMsg)
 			}
 		}
 	}
 } 
 
+func newAlertMessage(alert *models.Alert) *wshandler.AlertMessage {
+	return &wshandler.AlertMessage{
+		Type:                   "alert",
+		ID:                     alert.ID,
+		AlertType:              string(alert.Type),
+		Fingerprint:            alert.Fingerprint,
+		State:                  string(alert.State),
+		Dimension:              alert.Dimension,
+		Value:                  alert.Value,
+		Attributes:             alert.Attributes,
+		CurrentRate:            alert.CurrentRate,
+		PreviousRate:           alert.PreviousRate,
+		Baseline:               alert.Baseline,
+		HasBaseline:            alert.HasBaseline,
+		NewValue:               alert.NewValue,
+		DropPercentage:         alert.DropPercentage,
+		CustomerDropped:        alert.CustomerDropped,
+		GatewayRate:            alert.GatewayRate,
+		PreviousGatewayRate:    alert.PreviousGatewayRate,
+		GatewayDropPercentage:  alert.GatewayDropPercentage,
+		WeightedRate:           alert.WeightedRate,
+		PreviousWeightedRate:   alert.PreviousWeightedRate,
+		WeightedDropPercentage: alert.WeightedDropPercentage,
+		LostGMV:                alert.LostGMV,
+		Stage:                  alert.Stage,
+		Funnel:                 alert.Funnel,
+		ThresholdRule:          alert.ThresholdRule,
+		Threshold:              alert.Threshold,
+		PValue:                 alert.PValue,
+		IntervalLow:            alert.IntervalLow,
+		IntervalHigh:           alert.IntervalHigh,
+		CurrentTotal:           alert.CurrentTotal,
+		ExpectedTotal:          alert.ExpectedTotal,
+		VolumeDrop:             alert.VolumeDrop,
+		Latency:                alert.Latency,
+		Spike:                  alert.Spike,
+		Rule:                   alert.Rule,
+		SLO:                    alert.SLO,
+		Forecast:               alert.Forecast,
+		Timestamp:              alert.Timestamp,
+		StartedAt:              alert.StartedAt,
+		DrillDown:              alert.DrillDown,
+		Errors:                 alert.Errors,
+		RootCause:              alert.RootCause,
+		Confidence:             alert.Confidence,
+		Recommendations:        alert.Recommendations,
+	}
+}
+
 func initRedis(cfg *config.Config) *redis.Client {
     // Use a default Redis address if not specified in config
     addr := "localhost:6379"
@@ -0,0 +1,348 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// Manager deduplicates alerts by fingerprint and tracks each incident through
+// firing -> acknowledged -> resolved. It decides when a notification is due so
+// that a persisting drop is reported once rather than on every observer tick.
+type Manager struct {
+	config *Config
+	store  Store
+	mu     sync.Mutex
+	alerts map[string]*models.Alert // latest incident per fingerprint
+}
+
+// Store persists incidents as their lifecycle changes
+type Store interface {
+	SaveAlert(alert *models.Alert) error
+	OpenAlerts() ([]*models.Alert, error)
+}
+
+// Leadership tells whether this replica is the one raising alerts
+type Leadership interface {
+	IsLeader() bool
+}
+
+type Config struct {
+	// Leader, when set, marks this manager as one of several replicas. Only
+	// the leader's incidents are current; followers read them from the store.
+	Leader Leadership
+	// Cooldown is how long a recovery has to hold before it is notified. A
+	// value that recovers and drops again within the cooldown reopens the
+	// previous incident quietly instead of paging again.
+	Cooldown time.Duration
+	// RenotifyInterval re-sends a firing, unacknowledged alert after this long.
+	// Zero disables re-notification.
+	RenotifyInterval time.Duration
+	// Expiry resolves an open incident that has not been breached for this
+	// long, because its value was no longer judged at all: it went silent,
+	// fell below the minimum volume or was folded. It has to exceed the
+	// interval of the least frequent check.
+	Expiry time.Duration
+}
+
+func NewManager(config *Config, store Store) *Manager {
+	if config.Expiry == 0 {
+		config.Expiry = time.Hour
+	}
+	return &Manager{
+		config: config,
+		store:  store,
+		alerts: make(map[string]*models.Alert),
+	}
+}
+
+// Restore reloads the open incidents from the store so that a restart, or a
+// replica taking over as leader, neither forgets them nor notifies about them
+// again
+func (m *Manager) Restore() error {
+	if m.store == nil {
+		return nil
+	}
+	open, err := m.store.OpenAlerts()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alerts = make(map[string]*models.Alert, len(open))
+	for _, alert := range open {
+		m.alerts[alert.Fingerprint] = alert
+	}
+	log.Printf("Restored %d open alerts", len(open))
+	return nil
+}
+
+// Sync adopts acknowledgements that other replicas stored for the leader's
+// open incidents. An acknowledgement stored while the leader is saving the
+// same incident can still be overwritten, and has to be repeated.
+func (m *Manager) Sync() error {
+	if m.store == nil {
+		return nil
+	}
+	open, err := m.store.OpenAlerts()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stored := range open {
+		existing, ok := m.alerts[stored.Fingerprint]
+		if !ok || existing.ID != stored.ID {
+			continue
+		}
+		if stored.State == models.AlertStateAcknowledged && existing.State == models.AlertStateFiring {
+			existing.State = models.AlertStateAcknowledged
+			existing.AcknowledgedAt = stored.AcknowledgedAt
+		}
+	}
+	return nil
+}
+
+// follow reloads the incidents from the store when this replica is not the
+// leader, so that followers serve the leader's view
+func (m *Manager) follow() {
+	if m.config.Leader == nil || m.config.Leader.IsLeader() {
+		return
+	}
+	if err := m.Restore(); err != nil {
+		log.Printf("Error reloading alerts from the store: %v", err)
+	}
+}
+
+// Fire records a breach for the alert's fingerprint. It returns the alert to
+// notify about, or nil when the incident is already known and no notification
+// is due.
+func (m *Manager) Fire(alert *models.Alert, now time.Time) *models.Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.alerts[alert.Fingerprint]
+	if ok && existing.State != models.AlertStateResolved {
+		refresh(existing, alert)
+		if existing.State == models.AlertStateFiring && m.renotifyDue(existing, now) {
+			existing.LastNotifiedAt = now
+			m.save(existing)
+			return copyAlert(existing)
+		}
+		return nil
+	}
+
+	if ok && unannounced(existing) {
+		// Flapping within the cooldown: reopen the previous incident quietly
+		refresh(existing, alert)
+		existing.State = models.AlertStateFiring
+		existing.ResolvedAt = time.Time{}
+		m.save(existing)
+		return nil
+	}
+
+	incident := copyAlert(alert)
+	incident.ID = fmt.Sprintf("%s-%d", alert.Fingerprint, now.Unix())
+	incident.State = models.AlertStateFiring
+	incident.StartedAt = now
+	incident.LastNotifiedAt = now
+	m.alerts[alert.Fingerprint] = incident
+	m.save(incident)
+	return copyAlert(incident)
+}
+
+// Resolve marks the fingerprint as recovered. It returns the resolved alert to
+// notify about, or nil when nothing was open for the fingerprint or the
+// recovery is held back until it has lasted for the cooldown.
+func (m *Manager) Resolve(fingerprint string, now time.Time) *models.Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.alerts[fingerprint]
+	if !ok || existing.State == models.AlertStateResolved {
+		return nil
+	}
+	existing.State = models.AlertStateResolved
+	existing.ResolvedAt = now
+	if m.config.Cooldown > 0 {
+		m.save(existing)
+		return nil
+	}
+	existing.LastNotifiedAt = now
+	m.save(existing)
+	return copyAlert(existing)
+}
+
+// Expire resolves the open incidents whose last breach is older than the
+// expiry. Their recovery is notified by Settle like any other.
+func (m *Manager) Expire(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.alerts {
+		if existing.State == models.AlertStateResolved || now.Sub(existing.Timestamp) < m.config.Expiry {
+			continue
+		}
+		log.Printf("Alert %s expired: not breached since %s", existing.ID, existing.Timestamp.Format(time.RFC3339))
+		existing.State = models.AlertStateResolved
+		existing.ResolvedAt = now
+		m.save(existing)
+	}
+}
+
+// Settle returns the resolved alerts to notify about whose recovery has now
+// lasted for the cooldown
+func (m *Manager) Settle(now time.Time) []*models.Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var settled []*models.Alert
+	for _, existing := range m.alerts {
+		if !unannounced(existing) || now.Sub(existing.ResolvedAt) < m.config.Cooldown {
+			continue
+		}
+		existing.LastNotifiedAt = now
+		m.save(existing)
+		settled = append(settled, copyAlert(existing))
+	}
+	return settled
+}
+
+// Annotate stores what was learned about a notified incident after the fact,
+// such as its drill-down and analysis, so that it is kept with the incident
+func (m *Manager) Annotate(alert *models.Alert) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.alerts[alert.Fingerprint]
+	if !ok || existing.ID != alert.ID {
+		return
+	}
+	existing.DrillDown = alert.DrillDown
+	existing.Errors = alert.Errors
+	existing.RootCause = alert.RootCause
+	existing.Confidence = alert.Confidence
+	existing.Recommendations = alert.Recommendations
+	m.save(existing)
+}
+
+// Acknowledge stops re-notification for an open incident until it resolves
+func (m *Manager) Acknowledge(id string, now time.Time) (*models.Alert, error) {
+	m.follow()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.alerts {
+		if existing.ID != id {
+			continue
+		}
+		if existing.State == models.AlertStateResolved {
+			return nil, fmt.Errorf("alert %s is already resolved", id)
+		}
+		if existing.State == models.AlertStateFiring {
+			existing.State = models.AlertStateAcknowledged
+			existing.AcknowledgedAt = now
+			m.save(existing)
+		}
+		return copyAlert(existing), nil
+	}
+	return nil, fmt.Errorf("alert %s is not open", id)
+}
+
+// Active returns the incidents that are firing or acknowledged, newest first
+func (m *Manager) Active() []*models.Alert {
+	m.follow()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	active := []*models.Alert{}
+	for _, existing := range m.alerts {
+		if existing.State != models.AlertStateResolved {
+			active = append(active, copyAlert(existing))
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].StartedAt.After(active[j].StartedAt)
+	})
+	return active
+}
+
+func (m *Manager) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/alerts/active", m.listActive)
+	mux.HandleFunc("POST /api/v1/alerts/{id}/ack", m.acknowledge)
+}
+
+func (m *Manager) listActive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m.Active())
+}
+
+func (m *Manager) acknowledge(w http.ResponseWriter, r *http.Request) {
+	alert, err := m.Acknowledge(r.PathValue("id"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alert)
+}
+
+// save persists an incident, logging rather than failing the transition so
+// that alerting keeps working while the database is unavailable
+func (m *Manager) save(alert *models.Alert) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.SaveAlert(alert); err != nil {
+		log.Printf("Error persisting alert %s: %v", alert.ID, err)
+	}
+}
+
+func (m *Manager) renotifyDue(alert *models.Alert, now time.Time) bool {
+	if m.config.RenotifyInterval <= 0 {
+		return false
+	}
+	since := now.Sub(alert.LastNotifiedAt)
+	return since >= m.config.RenotifyInterval && since >= m.config.Cooldown
+}
+
+// unannounced reports whether an incident has resolved without its recovery
+// having been notified yet
+func unannounced(alert *models.Alert) bool {
+	return alert.State == models.AlertStateResolved && alert.LastNotifiedAt.Before(alert.ResolvedAt)
+}
+
+// refresh copies the latest measurements of a breach onto an open incident,
+// keeping the incident's identity, lifecycle and annotations
+func refresh(incident, latest *models.Alert) {
+	lifecycle := *incident
+	*incident = *latest
+	incident.ID = lifecycle.ID
+	incident.State = lifecycle.State
+	incident.StartedAt = lifecycle.StartedAt
+	incident.LastNotifiedAt = lifecycle.LastNotifiedAt
+	incident.AcknowledgedAt = lifecycle.AcknowledgedAt
+	incident.ResolvedAt = lifecycle.ResolvedAt
+	incident.DrillDown = lifecycle.DrillDown
+	incident.Errors = lifecycle.Errors
+	incident.RootCause = lifecycle.RootCause
+	incident.Confidence = lifecycle.Confidence
+	incident.Recommendations = lifecycle.Recommendations
+}
+
+// copyAlert returns a copy that can be handed to other goroutines
+func copyAlert(alert *models.Alert) *models.Alert {
+	copied := *alert
+	return &copied
+}
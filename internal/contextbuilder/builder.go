@@ -28,14 +28,44 @@ func NewContextBuilder(config *Config, redis *redis.Client) *ContextBuilder {
 
 func (b *ContextBuilder) BuildContext(ctx context.Context, alert *models.Alert) (*models.AnalysisContext, error) {
 	analysisContext := &models.AnalysisContext{
+		AlertType:     alert.Type,
+		ThresholdRule: alert.ThresholdRule,
+		Threshold:     alert.Threshold,
 		PaymentStats: &models.PaymentStats{
-			Dimension:      alert.Dimension,
-			Value:          alert.Value,
-			SuccessRate:    alert.CurrentRate,
-			PreviousRate:   alert.PreviousRate,
-			DropPercentage: alert.DropPercentage,
-			Timestamp:      alert.Timestamp,
+			Dimension:              alert.Dimension,
+			Value:                  alert.Value,
+			Attributes:             alert.Attributes,
+			SuccessRate:            alert.CurrentRate,
+			PreviousRate:           alert.PreviousRate,
+			Baseline:               alert.Baseline,
+			HasBaseline:            alert.HasBaseline,
+			NewValue:               alert.NewValue,
+			DropPercentage:         alert.DropPercentage,
+			CustomerDropped:        alert.CustomerDropped,
+			GatewayRate:            alert.GatewayRate,
+			PreviousGatewayRate:    alert.PreviousGatewayRate,
+			GatewayDropPercentage:  alert.GatewayDropPercentage,
+			WeightedRate:           alert.WeightedRate,
+			PreviousWeightedRate:   alert.PreviousWeightedRate,
+			WeightedDropPercentage: alert.WeightedDropPercentage,
+			LostGMV:                alert.LostGMV,
+			Funnel:                 alert.Funnel,
+			Stage:                  alert.Stage,
+			PValue:                 alert.PValue,
+			IntervalLow:            alert.IntervalLow,
+			IntervalHigh:           alert.IntervalHigh,
+			Total:                  alert.CurrentTotal,
+			ExpectedTotal:          alert.ExpectedTotal,
+			VolumeDrop:             alert.VolumeDrop,
+			Timestamp:              alert.Timestamp,
 		},
+		DrillDown: alert.DrillDown,
+		Errors:    alert.Errors,
+		Latency:   alert.Latency,
+		Spike:     alert.Spike,
+		Rule:      alert.Rule,
+		SLO:       alert.SLO,
+		Forecast:  alert.Forecast,
 	}
 
 	// Gather GitHub changes if token is provided
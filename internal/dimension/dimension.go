@@ -0,0 +1,185 @@
+package dimension
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/payment-monitor/pkg/config"
+	"github.com/yourusername/payment-monitor/pkg/models"
+	"gorm.io/gorm/schema"
+)
+
+// Field is a single attribute a dimension groups payments by
+type Field struct {
+	Name string // attribute name reported on stats and alerts
+	Expr string // SQL expression selecting the attribute from payments
+}
+
+// Other is the value of every field of the bucket that folds together the
+// values a bounded dimension does not monitor individually
+const Other = "__other__"
+
+// Dimension is a named combination of payments columns that is monitored
+type Dimension struct {
+	Name   string
+	Fields []Field
+	// TopK and MinVolume bound the values monitored individually to the K
+	// with the most payments and to those with at least MinVolume payments,
+	// across the current and baseline windows. The rest are folded into
+	// Other. Zero disables either bound.
+	TopK      int
+	MinVolume int
+}
+
+// builtins are the dimensions that can be enabled without listing columns
+var builtins = map[string][]string{
+	"gateway":          {"gateway"},
+	"gateway_method":   {"gateway", "method"},
+	"gateway_merchant": {"gateway", "merchant_id"},
+	"gateway_terminal": {"gateway", "terminal_id"},
+}
+
+// Build returns the enabled dimensions declared in the monitoring config. Any
+// dimension that cannot be resolved to payments columns or JSON fields is an
+// error, as is a JSON field name that means different things in different
+// dimensions.
+func Build(cfgs []config.DimensionConfig) ([]*Dimension, error) {
+	var dimensions []*Dimension
+	seen := make(map[string]bool)
+	jsonFields := make(map[string]string)
+	for _, cfg := range cfgs {
+		if !cfg.Enabled {
+			continue
+		}
+		if seen[cfg.Name] {
+			return nil, fmt.Errorf("dimension %q is declared more than once", cfg.Name)
+		}
+		seen[cfg.Name] = true
+
+		var dim *Dimension
+		if len(cfg.Columns) == 0 && len(cfg.Fields) > 0 {
+			if cfg.Name == "" {
+				return nil, fmt.Errorf("dimension name is required")
+			}
+			dim = &Dimension{Name: cfg.Name}
+		} else {
+			var err error
+			if dim, err = New(cfg.Name, cfg.Columns); err != nil {
+				return nil, err
+			}
+		}
+		for _, fieldConfig := range cfg.Fields {
+			field, err := JSONField(fieldConfig)
+			if err != nil {
+				return nil, fmt.Errorf("dimension %q: %v", cfg.Name, err)
+			}
+			if dim.HasField(field.Name) {
+				return nil, fmt.Errorf("dimension %q lists field %q more than once", cfg.Name, field.Name)
+			}
+			if expr, ok := jsonFields[field.Name]; ok && expr != field.Expr {
+				return nil, fmt.Errorf("dimension %q: JSON field %q is declared differently by another dimension", cfg.Name, field.Name)
+			}
+			jsonFields[field.Name] = field.Expr
+			dim.Fields = append(dim.Fields, field)
+		}
+		if cfg.TopK < 0 || cfg.MinVolume < 0 {
+			return nil, fmt.Errorf("dimension %q: top_k and minimum_volume must not be negative", cfg.Name)
+		}
+		dim.TopK, dim.MinVolume = cfg.TopK, cfg.MinVolume
+		dimensions = append(dimensions, dim)
+	}
+	return dimensions, nil
+}
+
+// New creates a dimension grouping by the given payments columns. When no
+// columns are given the name is looked up in the built-in dimensions, falling
+// back to a column of the same name.
+func New(name string, columns []string) (*Dimension, error) {
+	if name == "" {
+		return nil, fmt.Errorf("dimension name is required")
+	}
+	if len(columns) == 0 {
+		if builtin, ok := builtins[name]; ok {
+			columns = builtin
+		} else {
+			columns = []string{name}
+		}
+	}
+
+	dim := &Dimension{Name: name}
+	seen := make(map[string]bool)
+	for _, column := range columns {
+		column = strings.TrimSpace(column)
+		if seen[column] {
+			return nil, fmt.Errorf("dimension %q lists column %q more than once", name, column)
+		}
+		seen[column] = true
+
+		field, err := ColumnField(column)
+		if err != nil {
+			return nil, fmt.Errorf("unknown dimension %q: %v", name, err)
+		}
+		dim.Fields = append(dim.Fields, field)
+	}
+	return dim, nil
+}
+
+// ColumnField returns the field for a payments column, which must be a
+// groupable column
+func ColumnField(column string) (Field, error) {
+	if !isGroupableColumn(column) {
+		return Field{}, fmt.Errorf("%q is not a groupable payments column", column)
+	}
+	return Field{Name: column, Expr: column}, nil
+}
+
+// Bounded reports whether the dimension folds some values into Other
+func (d *Dimension) Bounded() bool {
+	return d.TopK > 0 || d.MinVolume > 0
+}
+
+// HasField reports whether the dimension groups by the named field
+func (d *Dimension) HasField(name string) bool {
+	for _, field := range d.Fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Exprs returns the SQL expressions of the dimension fields in order
+func (d *Dimension) Exprs() []string {
+	exprs := make([]string, len(d.Fields))
+	for i, field := range d.Fields {
+		exprs[i] = field.Expr
+	}
+	return exprs
+}
+
+var (
+	paymentSchemaOnce sync.Once
+	paymentSchema     *schema.Schema
+	paymentSchemaErr  error
+)
+
+// isGroupableColumn reports whether column is a string column of the payments
+// table. Only those are accepted so that config values never reach SQL
+// unchecked.
+func isGroupableColumn(column string) bool {
+	field := columnOf(column)
+	return field != nil && field.DataType == schema.String
+}
+
+// columnOf returns the payments schema field of a column, or nil if there is
+// no such column
+func columnOf(column string) *schema.Field {
+	paymentSchemaOnce.Do(func() {
+		paymentSchema, paymentSchemaErr = schema.Parse(&models.Payment{}, &sync.Map{}, schema.NamingStrategy{})
+	})
+	if paymentSchemaErr != nil {
+		return nil
+	}
+	return paymentSchema.FieldsByDBName[column]
+}
@@ -0,0 +1,141 @@
+package dimension
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yourusername/payment-monitor/pkg/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// JSON field types. Values are coerced to the type and rendered as text, so
+// that e.g. 4 and "4" or true and "yes" group together; values that cannot be
+// coerced count as missing.
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+var (
+	fieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	pathKeyPattern   = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+	numberPattern    = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+)
+
+// tryJSONBFunction parses text as JSON, returning NULL rather than failing on
+// text that is not JSON. Text columns such as notes are read through it.
+const tryJSONBFunction = `CREATE OR REPLACE FUNCTION pm_try_jsonb(value text) RETURNS jsonb AS $$
+BEGIN
+	RETURN value::jsonb;
+EXCEPTION WHEN others THEN
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE`
+
+// Migrate creates the SQL functions JSON fields rely on
+func Migrate(db *gorm.DB) error {
+	return db.Exec(tryJSONBFunction).Error
+}
+
+// JSONField returns the field reading a path of a JSON document in payments.
+// The column is either a JSONB column or a text column holding JSON, such as
+// notes; the name and path keys are checked so that config values never
+// reach SQL unchecked.
+func JSONField(cfg config.JSONFieldConfig) (Field, error) {
+	if !fieldNamePattern.MatchString(cfg.Name) {
+		return Field{}, fmt.Errorf("JSON field name %q must be lower case letters, digits and underscores", cfg.Name)
+	}
+	if column := columnOf(cfg.Name); column != nil {
+		return Field{}, fmt.Errorf("JSON field %q has the name of a payments column", cfg.Name)
+	}
+
+	var document string
+	column := columnOf(cfg.Column)
+	switch {
+	case column == nil:
+		return Field{}, fmt.Errorf("JSON field %q: %q is not a payments column", cfg.Name, cfg.Column)
+	case column.GORMDataType == "jsonb":
+		document = fmt.Sprintf("%q", cfg.Column)
+	case column.DataType == schema.String:
+		document = fmt.Sprintf("pm_try_jsonb(%q)", cfg.Column)
+	default:
+		return Field{}, fmt.Errorf("JSON field %q: column %q holds no JSON", cfg.Name, cfg.Column)
+	}
+
+	keys := strings.Split(cfg.Path, ".")
+	for _, key := range keys {
+		if !pathKeyPattern.MatchString(key) {
+			return Field{}, fmt.Errorf("JSON field %q: invalid path %q", cfg.Name, cfg.Path)
+		}
+	}
+	path := "'{" + strings.Join(keys, ",") + "}'"
+	text := fmt.Sprintf("(%s #>> %s)", document, path)
+
+	var expr string
+	switch cfg.Type {
+	case "", TypeString:
+		expr = text
+	case TypeNumber:
+		// Portable trim_scale, which needs PostgreSQL 13: integers through
+		// numeric, and decimals without trailing zeros or a trailing point
+		expr = fmt.Sprintf(`CASE WHEN btrim(%s) ~ '^-?[0-9]+$' THEN btrim(%s)::numeric::text `+
+			`WHEN btrim(%s) ~ '^-?[0-9]+\.[0-9]+$' THEN rtrim(rtrim(btrim(%s)::numeric::text, '0'), '.') END`, text, text, text, text)
+	case TypeBoolean:
+		expr = fmt.Sprintf("CASE WHEN lower(%s) IN ('true', 't', 'yes', 'y', '1') THEN 'true' WHEN lower(%s) IN ('false', 'f', 'no', 'n', '0') THEN 'false' END", text, text)
+	default:
+		return Field{}, fmt.Errorf("JSON field %q: unknown type %q", cfg.Name, cfg.Type)
+	}
+
+	// Missing paths, JSON nulls and empty strings are all missing
+	expr = fmt.Sprintf("NULLIF(%s, '')", expr)
+	if cfg.Default != "" {
+		value, ok := coerce(cfg.Type, cfg.Default)
+		if !ok {
+			return Field{}, fmt.Errorf("JSON field %q: default %q is not a %s", cfg.Name, cfg.Default, cfg.Type)
+		}
+		expr = fmt.Sprintf("COALESCE(%s, '%s')", expr, strings.ReplaceAll(value, "'", "''"))
+	}
+	return Field{Name: cfg.Name, Expr: expr}, nil
+}
+
+// coerce renders a value of the given type as the SQL of JSONField renders
+// extracted values, so that a default groups with the values equal to it
+func coerce(typ, value string) (string, bool) {
+	switch typ {
+	case TypeNumber:
+		value = strings.TrimSpace(value)
+		if !numberPattern.MatchString(value) {
+			return "", false
+		}
+		negative := strings.HasPrefix(value, "-")
+		whole, fraction, _ := strings.Cut(strings.TrimPrefix(value, "-"), ".")
+		whole = strings.TrimLeft(whole, "0")
+		if whole == "" {
+			whole = "0"
+		}
+		fraction = strings.TrimRight(fraction, "0")
+		if whole == "0" && fraction == "" {
+			negative = false
+		}
+		if fraction != "" {
+			whole += "." + fraction
+		}
+		if negative {
+			whole = "-" + whole
+		}
+		return whole, true
+	case TypeBoolean:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "t", "yes", "y", "1":
+			return "true", true
+		case "false", "f", "no", "n", "0":
+			return "false", true
+		}
+		return "", false
+	default:
+		return value, value != ""
+	}
+}
@@ -0,0 +1,226 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/llm"
+	"github.com/yourusername/payment-monitor/pkg/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// Store persists alerts and their analyses in Postgres and serves them back
+// through the history API
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Filter narrows down the alerts returned by List. Zero values match anything.
+type Filter struct {
+	Type      string
+	Dimension string
+	Value     string
+	State     string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// AlertDetail is an alert with every analysis produced for it
+type AlertDetail struct {
+	Alert    *models.Alert          `json:"alert"`
+	Analyses []models.AlertAnalysis `json:"analyses"`
+}
+
+// SaveAlert inserts or updates the record of an alert incident
+func (s *Store) SaveAlert(alert *models.Alert) error {
+	record, err := models.NewAlertRecord(alert)
+	if err != nil {
+		return fmt.Errorf("error encoding alert %s: %v", alert.ID, err)
+	}
+	if err := s.db.Save(record).Error; err != nil {
+		return fmt.Errorf("error saving alert %s: %v", alert.ID, err)
+	}
+	return nil
+}
+
+// SaveAnalysis stores the context an alert was analysed with and the result
+func (s *Store) SaveAnalysis(alertID string, context *models.AnalysisContext, result *llm.AnalysisResult) error {
+	contextJSON, err := json.Marshal(context)
+	if err != nil {
+		return fmt.Errorf("error encoding analysis context: %v", err)
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("error encoding analysis result: %v", err)
+	}
+
+	analysis := &models.AlertAnalysis{
+		AlertID: alertID,
+		Context: contextJSON,
+		Result:  resultJSON,
+	}
+	if err := s.db.Create(analysis).Error; err != nil {
+		return fmt.Errorf("error saving analysis for alert %s: %v", alertID, err)
+	}
+	return nil
+}
+
+// OpenAlerts returns the incidents that were not resolved, or whose recovery
+// was not notified yet
+func (s *Store) OpenAlerts() ([]*models.Alert, error) {
+	var records []models.AlertRecord
+	if err := s.db.Where("state <> ? OR last_notified_at < resolved_at", string(models.AlertStateResolved)).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return decodeRecords(records)
+}
+
+// List returns the alerts matching the filter, most recent first
+func (s *Store) List(filter Filter) ([]*models.Alert, error) {
+	query := s.db.Model(&models.AlertRecord{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Dimension != "" {
+		query = query.Where("dimension = ?", filter.Dimension)
+	}
+	if filter.Value != "" {
+		query = query.Where("value = ?", filter.Value)
+	}
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("started_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("started_at < ?", filter.To)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	var records []models.AlertRecord
+	if err := query.Order("started_at DESC").Limit(limit).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return decodeRecords(records)
+}
+
+// Get returns an alert with its analyses, or nil if it does not exist
+func (s *Store) Get(id string) (*AlertDetail, error) {
+	var record models.AlertRecord
+	if err := s.db.First(&record, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	alert, err := record.Alert()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding alert %s: %v", id, err)
+	}
+
+	analyses := []models.AlertAnalysis{}
+	if err := s.db.Where("alert_id = ?", id).Order("created_at").Find(&analyses).Error; err != nil {
+		return nil, err
+	}
+	return &AlertDetail{Alert: alert, Analyses: analyses}, nil
+}
+
+func (s *Store) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/alerts", s.listAlerts)
+	mux.HandleFunc("GET /api/v1/alerts/{id}", s.getAlert)
+}
+
+func (s *Store) listAlerts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := Filter{
+		Type:      query.Get("type"),
+		Dimension: query.Get("dimension"),
+		Value:     query.Get("value"),
+		State:     query.Get("state"),
+	}
+
+	var err error
+	if filter.From, err = parseTime(query.Get("from")); err != nil {
+		http.Error(w, fmt.Sprintf("invalid from: %v", err), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTime(query.Get("to")); err != nil {
+		http.Error(w, fmt.Sprintf("invalid to: %v", err), http.StatusBadRequest)
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, fmt.Sprintf("invalid limit: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	alerts, err := s.List(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+func (s *Store) getAlert(w http.ResponseWriter, r *http.Request) {
+	detail, err := s.Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if detail == nil {
+		http.Error(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// parseTime accepts RFC3339 timestamps or unix seconds
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func decodeRecords(records []models.AlertRecord) ([]*models.Alert, error) {
+	alerts := make([]*models.Alert, 0, len(records))
+	for i := range records {
+		alert, err := records[i].Alert()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding alert %s: %v", records[i].ID, err)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
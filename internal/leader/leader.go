@@ -0,0 +1,171 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// renewScript extends the lease only if this replica still holds it
+const renewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+// releaseScript deletes the lease only if this replica still holds it
+const releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+// Elector elects one leader among the replicas sharing a Redis lease. The
+// leader renews the lease well before it expires; if the leader dies or loses
+// Redis, another replica takes over once the lease expires, so failover takes
+// at most TTL plus one renew interval. While Redis is unreachable no replica
+// can hold the lease, so none leads: observation pauses rather than every
+// replica paging the same incidents on its own.
+type Elector struct {
+	client *redis.Client
+	config *Config
+	id     string
+
+	mu        sync.Mutex
+	leading   bool
+	expiresAt time.Time // local deadline of the lease held
+	onElected []func()
+}
+
+type Config struct {
+	Key           string
+	TTL           time.Duration
+	RenewInterval time.Duration
+}
+
+func NewElector(client *redis.Client, config *Config) *Elector {
+	if config.Key == "" {
+		config.Key = "payment-monitor:leader"
+	}
+	if config.TTL == 0 {
+		config.TTL = 15 * time.Second
+	}
+	if config.RenewInterval == 0 {
+		config.RenewInterval = config.TTL / 3
+	}
+
+	host, _ := os.Hostname()
+	return &Elector{
+		client: client,
+		config: config,
+		id:     fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// OnElected registers a function to run each time this replica becomes the
+// leader, before IsLeader reports true
+func (e *Elector) OnElected(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onElected = append(e.onElected, fn)
+}
+
+// IsLeader reports whether this replica holds the lease. A lease that could
+// not be renewed in time is given up locally even if Redis is unreachable, so
+// that two replicas never both consider themselves leader.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading && time.Now().Before(e.expiresAt)
+}
+
+// Run campaigns for and renews the lease until ctx is done
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.RenewInterval)
+	defer ticker.Stop()
+
+	e.tick()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.tick()
+		}
+	}
+}
+
+func (e *Elector) tick() {
+	// The deadline is taken before the request so that it never outlives the
+	// lease Redis holds
+	deadline := time.Now().Add(e.config.TTL)
+
+	if e.IsLeader() {
+		renewed, err := e.client.Eval(renewScript, []string{e.config.Key}, e.id, e.config.TTL.Milliseconds()).Int64()
+		if err != nil {
+			log.Printf("Error renewing leader lease: %v", err)
+			return
+		}
+		if renewed == 0 {
+			e.stepDown("lease was lost")
+			return
+		}
+		e.mu.Lock()
+		e.expiresAt = deadline
+		e.mu.Unlock()
+		return
+	}
+
+	e.mu.Lock()
+	wasLeading := e.leading
+	e.mu.Unlock()
+	if wasLeading {
+		e.stepDown("lease expired before it could be renewed")
+	}
+
+	acquired, err := e.client.SetNX(e.config.Key, e.id, e.config.TTL).Result()
+	if err != nil {
+		log.Printf("Error acquiring leader lease: %v", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	log.Printf("Elected leader as %s", e.id)
+	e.mu.Lock()
+	callbacks := append([]func(){}, e.onElected...)
+	e.mu.Unlock()
+	for _, fn := range callbacks {
+		fn()
+	}
+
+	e.mu.Lock()
+	e.leading = true
+	e.expiresAt = deadline
+	e.mu.Unlock()
+}
+
+func (e *Elector) stepDown(reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leading {
+		log.Printf("Stepping down as leader: %s", reason)
+	}
+	e.leading = false
+}
+
+// Release gives up the lease on shutdown so that another replica can take
+// over without waiting for it to expire
+func (e *Elector) Release() {
+	if !e.IsLeader() {
+		return
+	}
+	e.stepDown("shutting down")
+	if err := e.client.Eval(releaseScript, []string{e.config.Key}, e.id).Err(); err != nil {
+		log.Printf("Error releasing leader lease: %v", err)
+	}
+}
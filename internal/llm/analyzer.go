@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
@@ -136,13 +137,47 @@ func (a *Analyzer) buildPrompt(context *models.AnalysisContext) string {
 	prompt := fmt.Sprintf(`
 Payment Success Rate Analysis Request:
 
+Alert Type: %s
+Threshold: %.2f (rule: %s)
+Alert Rule: %s
 Dimension: %s
 Value: %s
+Attributes:
+%s
 Current Success Rate: %.2f%%
-Previous Success Rate: %.2f%%
+Previous Success Rate: %.2f%% (baseline: %s)
+New Dimension Value (no data in the baseline windows): %t
 Drop Percentage: %.2f%%
+Gateway-Attributable Success Rate: %.2f%% (baseline: %.2f%%, drop: %.2f%%, customer drop-offs: %d)
+Amount-Weighted Success Rate: %.2f%% (baseline: %.2f%%, drop: %.2f%%)
+Estimated Lost GMV: %.0f (minor units of the base currency)
+Regressed Funnel Stage: %s
+P-Value: %.4f
+Current Success Rate Confidence Interval: %.2f%% - %.2f%%
+Current Volume: %d payments (expected: %.1f, %.2f%% below)
 Timestamp: %s
 
+Funnel (stage conversion, current vs baseline):
+%s
+
+Drill-Down (child values ranked by share of the excess failures):
+%s
+
+Error Breakdown of Failed Payments (current vs baseline):
+%s
+
+Latency (seconds, current vs baseline):
+%s
+
+Refund or Dispute Spike (per captured payment):
+%s
+
+SLO Error Budget Burn:
+%s
+
+Forecast (expected for this time of day and week, with the learned band):
+%s
+
 Recent GitHub Changes:
 %s
 
@@ -153,7 +188,8 @@ Active Experiment Changes:
 %s
 
 Please analyze this information and provide:
-1. The most likely root cause of the success rate drop
+1. The most likely root cause of what the alert reports:
+%s
 2. Your confidence level in this analysis (0-1)
 3. Recommended actions to address the issue
 4. Any related code changes that might be contributing to the problem
@@ -171,20 +207,229 @@ Format your response as a JSON object with the following structure:
 
 IMPORTANT: Respond *only* with the valid JSON object requested above. Do not include any introductory text, explanations, summaries, or markdown formatting before or after the JSON.
 `,
+		context.AlertType,
+		context.Threshold,
+		context.ThresholdRule,
+		a.formatRule(context.Rule),
 		context.PaymentStats.Dimension,
 		context.PaymentStats.Value,
+		a.formatAttributes(context.PaymentStats.Attributes),
 		context.PaymentStats.SuccessRate,
 		context.PaymentStats.PreviousRate,
+		a.formatBaseline(context.PaymentStats),
+		context.PaymentStats.NewValue,
 		context.PaymentStats.DropPercentage,
+		context.PaymentStats.GatewayRate,
+		context.PaymentStats.PreviousGatewayRate,
+		context.PaymentStats.GatewayDropPercentage,
+		context.PaymentStats.CustomerDropped,
+		context.PaymentStats.WeightedRate,
+		context.PaymentStats.PreviousWeightedRate,
+		context.PaymentStats.WeightedDropPercentage,
+		context.PaymentStats.LostGMV,
+		a.formatStage(context.PaymentStats.Stage),
+		context.PaymentStats.PValue,
+		context.PaymentStats.IntervalLow,
+		context.PaymentStats.IntervalHigh,
+		context.PaymentStats.Total,
+		context.PaymentStats.ExpectedTotal,
+		context.PaymentStats.VolumeDrop,
 		context.PaymentStats.Timestamp.Format(time.RFC3339),
+		a.formatFunnel(context.PaymentStats.Funnel),
+		a.formatDrillDown(context.DrillDown),
+		a.formatErrors(context.Errors),
+		a.formatLatency(context.Latency),
+		a.formatSpike(context.Spike),
+		a.formatSLO(context.SLO),
+		a.formatForecast(context.Forecast),
 		a.formatGitHubChanges(context.RecentChanges),
 		a.formatLogs(context.LogEntries),
 		a.formatExperiments(context.Experiments),
+		a.formatGuidance(context),
 	)
 
 	return prompt
 }
 
+// formatGuidance tells the model what to explain for the type of the alert
+func (a *Analyzer) formatGuidance(context *models.AnalysisContext) string {
+	var guidance string
+	switch context.AlertType {
+	case models.AlertTypeVolumeDrop:
+		guidance = "   The drop in payment volume. Look for upstream traffic loss (checkout, routing, merchant integration) rather than payments failing."
+	case models.AlertTypeSilent:
+		guidance = "   Why the value stopped receiving payments altogether, such as routing changes, a disabled gateway or method, or ingestion stopping."
+	case models.AlertTypeLatency:
+		guidance = "   The latency regression of the stages that regressed, such as gateway or issuer slowness, retries or timeouts."
+	case models.AlertTypeRule:
+		guidance = "   Why the stats match the condition of the alert rule. The rule, not a drop threshold, raised the alert, so explain the terms of its expression that hold, and use its severity and labels to pitch the recommendations."
+	case models.AlertTypeSLOBurn:
+		guidance = "   Why failures are spending the error budget of the SLO faster than its target allows over both burn windows. Compare the failures with the objective's target rather than with a baseline, and weigh the remaining budget when recommending how urgently to act."
+	case models.AlertTypeRefundRate, models.AlertTypeDisputeRate:
+		guidance = "   The spike in refunds or disputes per captured payment. These trail the payments they refer to, so consider what changed in the captured payments of the previous hours or days (fraud, fulfilment, duplicate captures, merchant issues) rather than the current success rate."
+	default:
+		guidance = "   The success rate drop, using the regressed funnel stage, the drill-down and the error breakdown to locate it."
+	}
+	if context.Forecast != nil {
+		// The forecast detector raised the alert
+		guidance += "\n   The value fell outside the band learned for this hour of the day and week, so explain why it departs from its usual pattern at this time rather than from the previous window."
+	}
+	return guidance
+}
+
+func (a *Analyzer) formatAttributes(attrs models.Attributes) string {
+	var formatted string
+	for _, attr := range attrs {
+		formatted += fmt.Sprintf("- %s: %s\n", attr.Name, attr.Value)
+	}
+	return formatted
+}
+
+func (a *Analyzer) formatRule(rule *models.RuleMatch) string {
+	if rule == nil {
+		return "none"
+	}
+	formatted := fmt.Sprintf("%s (%s, severity %s)", rule.Name, rule.Expression, rule.Severity)
+	names := make([]string, 0, len(rule.Labels))
+	for name := range rule.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		formatted += fmt.Sprintf(", %s=%s", name, rule.Labels[name])
+	}
+	return formatted
+}
+
+func (a *Analyzer) formatSLO(burn *models.SLOBurn) string {
+	if burn == nil {
+		return "No SLO burn for this alert."
+	}
+	return fmt.Sprintf("- Objective %s: %.2f%% target, %.2f%% of the error budget left\n"+
+		"- %s burn: %.1fx over %s (%d payments, %.2f%% success) and %.1fx over %s (%d payments), alerting at %.1fx\n",
+		burn.Objective, burn.Target, burn.BudgetRemaining,
+		burn.Window, burn.LongBurnRate, burn.LongWindow, burn.LongTotal, burn.SuccessRate,
+		burn.ShortBurnRate, burn.ShortWindow, burn.ShortTotal, burn.Factor)
+}
+
+func (a *Analyzer) formatForecast(forecast *models.Forecast) string {
+	if forecast == nil {
+		return "No forecast for this alert."
+	}
+	return fmt.Sprintf("- %s: %.2f, expected %.2f (band %.2f - %.2f, %.1f standard deviations off)\n",
+		forecast.Metric, forecast.Actual, forecast.Expected, forecast.Lower, forecast.Upper, forecast.Deviation)
+}
+
+func (a *Analyzer) formatBaseline(stats *models.PaymentStats) string {
+	if !stats.HasBaseline {
+		return "none, nothing to compare with"
+	}
+	return stats.Baseline
+}
+
+func (a *Analyzer) formatStage(stage string) string {
+	if stage == "" {
+		return "none"
+	}
+	return stage
+}
+
+func (a *Analyzer) formatFunnel(stages []models.FunnelStage) string {
+	if len(stages) == 0 {
+		return "No funnel data available."
+	}
+
+	var formatted string
+	for _, s := range stages {
+		formatted += fmt.Sprintf("- %s: %.2f%% (%d of %d) vs baseline %.2f%%, drop %.2f points\n",
+			s.Stage,
+			s.Conversion,
+			s.Passed,
+			s.Entered,
+			s.BaselineConversion,
+			s.Drop,
+		)
+	}
+	return formatted
+}
+
+func (a *Analyzer) formatDrillDown(contributions []models.Contribution) string {
+	if len(contributions) == 0 {
+		return "No drill-down available."
+	}
+
+	var formatted string
+	for _, c := range contributions {
+		formatted += fmt.Sprintf("- %s=%s: %.1f%% of excess failures (%.0f), success rate %.2f%% vs baseline %.2f%% over %d payments\n",
+			c.Attribute,
+			c.Value,
+			c.Share,
+			c.ExcessFailures,
+			c.SuccessRate,
+			c.BaselineRate,
+			c.Total,
+		)
+	}
+	return formatted
+}
+
+func (a *Analyzer) formatErrors(errors []models.ErrorCount) string {
+	if len(errors) == 0 {
+		return "No error breakdown available."
+	}
+
+	var formatted string
+	for _, e := range errors {
+		formatted += fmt.Sprintf("- %s %s: %d now (%.1f%% of failures) vs %d in baseline (%.1f%%)",
+			e.Field,
+			e.Value,
+			e.Current,
+			e.CurrentShare,
+			e.Baseline,
+			e.BaselineShare,
+		)
+		if e.New {
+			formatted += " [NEW]"
+		}
+		formatted += "\n"
+	}
+	return formatted
+}
+
+func (a *Analyzer) formatSpike(spike *models.RateSpike) string {
+	if spike == nil {
+		return "Not applicable."
+	}
+	return fmt.Sprintf("- %s rate: %.2f%% (%d of %d captured) vs baseline %.2f%% (%d of %d), up %.2f points\n",
+		spike.Metric,
+		spike.Rate, spike.Events, spike.Captured,
+		spike.BaselineRate, spike.BaselineEvents, spike.BaselineCaptured,
+		spike.Increase,
+	)
+}
+
+func (a *Analyzer) formatLatency(latency []models.LatencyStats) string {
+	if len(latency) == 0 {
+		return "No latency data available."
+	}
+
+	var formatted string
+	for _, l := range latency {
+		formatted += fmt.Sprintf("- %s: p50 %.1f vs %.1f, p95 %.1f vs %.1f, p99 %.1f vs %.1f (%d vs %d payments)",
+			l.Metric,
+			l.P50, l.BaselineP50,
+			l.P95, l.BaselineP95,
+			l.P99, l.BaselineP99,
+			l.Count, l.BaselineCount,
+		)
+		if l.Regressed {
+			formatted += fmt.Sprintf(" [REGRESSED: p%d up %.1fs]", l.Percentile, l.Increase)
+		}
+		formatted += "\n"
+	}
+	return formatted
+}
+
 func (a *Analyzer) formatGitHubChanges(changes []models.GitHubChange) string {
 	if len(changes) == 0 {
 		return "No recent changes found."
@@ -0,0 +1,149 @@
+package observer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/rollup"
+)
+
+// BaselineStrategy selects the windows the current window is compared against
+type BaselineStrategy string
+
+const (
+	// BaselinePreviousWindow compares against the window right before the current one
+	BaselinePreviousWindow BaselineStrategy = "previous_window"
+	// BaselineSameWindowYesterday compares against the same time of day yesterday
+	BaselineSameWindowYesterday BaselineStrategy = "same_window_yesterday"
+	// BaselineSameWindowLastWeek compares against the same time of day a week ago
+	BaselineSameWindowLastWeek BaselineStrategy = "same_window_last_week"
+	// BaselineMedianOfWeeks compares against the median of the same window over
+	// the last N weeks
+	BaselineMedianOfWeeks BaselineStrategy = "median_of_weeks"
+)
+
+// ParseBaselineStrategy validates a strategy name from config. An empty name
+// selects the previous window.
+func ParseBaselineStrategy(name string) (BaselineStrategy, error) {
+	switch strategy := BaselineStrategy(name); strategy {
+	case "":
+		return BaselinePreviousWindow, nil
+	case BaselinePreviousWindow, BaselineSameWindowYesterday, BaselineSameWindowLastWeek, BaselineMedianOfWeeks:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown baseline strategy: %s", name)
+	}
+}
+
+// timeWindow is a half-open [From, To) range of payment creation times
+type timeWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+// buckets returns the range of rollup buckets covering the window, widened to
+// whole minutes
+func (w timeWindow) buckets() (int64, int64) {
+	return rollup.Buckets(w.From, w.To)
+}
+
+// lookback is how far back from now the observer reads, up to the start of
+// the oldest baseline window or the longest objective window
+func (o *Observer) lookback(now time.Time) time.Duration {
+	lookback := o.config.CurrentWindow
+	if o.config.SLO != nil && o.config.SLO.Lookback() > lookback {
+		lookback = o.config.SLO.Lookback()
+	}
+	windows := o.baselineWindows(now)
+	if fallback, ok := o.fallbackWindow(now); ok {
+		windows = append(windows, fallback)
+	}
+	for _, window := range windows {
+		if back := now.Sub(window.From); back > lookback {
+			lookback = back
+		}
+	}
+	return lookback
+}
+
+// currentWindow returns the window being monitored, ending at now
+func (o *Observer) currentWindow(now time.Time) timeWindow {
+	return timeWindow{From: now.Add(-o.config.CurrentWindow), To: now}
+}
+
+// baselineWindows returns the windows the configured strategy compares the
+// current window against
+func (o *Observer) baselineWindows(now time.Time) []timeWindow {
+	current := o.currentWindow(now)
+	shifted := func(offset time.Duration) timeWindow {
+		to := current.To.Add(-offset)
+		return timeWindow{From: to.Add(-o.config.BaselineWindow), To: to}
+	}
+
+	switch o.config.Baseline {
+	case BaselineSameWindowYesterday:
+		return []timeWindow{shifted(24 * time.Hour)}
+	case BaselineSameWindowLastWeek:
+		return []timeWindow{shifted(7 * 24 * time.Hour)}
+	case BaselineMedianOfWeeks:
+		windows := make([]timeWindow, 0, o.config.BaselineWeeks)
+		for week := 1; week <= o.config.BaselineWeeks; week++ {
+			windows = append(windows, shifted(time.Duration(week)*7*24*time.Hour))
+		}
+		return windows
+	default:
+		return []timeWindow{{From: current.From.Add(-o.config.BaselineWindow), To: current.From}}
+	}
+}
+
+// fallbackWindow returns the longer window a value without any baseline data
+// is compared against, ending where the current window starts
+func (o *Observer) fallbackWindow(now time.Time) (timeWindow, bool) {
+	if o.config.FallbackWindow <= 0 {
+		return timeWindow{}, false
+	}
+	current := o.currentWindow(now)
+	return timeWindow{From: current.From.Add(-o.config.FallbackWindow), To: current.From}, true
+}
+
+// resolveBaseline returns the baseline of a value and its label. A value with
+// no data in the baseline windows is new: it falls back to the fallback
+// window and then to the absolute success-rate floor. ok is false when none
+// of them applies.
+func (o *Observer) resolveBaseline(value *valueStats) (baseline windowStats, label string, newValue, ok bool) {
+	if baseline, ok := combineBaselines(value.Baselines); ok {
+		return baseline, o.baselineLabel(), false, true
+	}
+	if value.Fallback.Total > 0 {
+		return value.Fallback, fmt.Sprintf("fallback(%s)", o.config.FallbackWindow), true, true
+	}
+	if floor := o.config.SuccessRateFloor; floor > 0 {
+		return windowStats{SuccessRate: floor, GatewayRate: floor, WeightedRate: floor}, fmt.Sprintf("floor(%g%%)", floor), true, true
+	}
+	return windowStats{}, "", true, false
+}
+
+// baselineLabel describes the baseline for alerts, e.g. "median_of_weeks(4)"
+func (o *Observer) baselineLabel() string {
+	if o.config.Baseline == BaselineMedianOfWeeks {
+		return fmt.Sprintf("%s(%d)", o.config.Baseline, o.config.BaselineWeeks)
+	}
+	return string(o.config.Baseline)
+}
+
+// combineBaselines reduces the per-window stats of one dimension value to a
+// single baseline. With several windows the median success rate is used; for
+// an even number of windows the lower middle one is taken so that the counts
+// still come from a real window.
+func combineBaselines(windows []windowStats) (windowStats, bool) {
+	if len(windows) == 0 {
+		return windowStats{}, false
+	}
+	sorted := make([]windowStats, len(windows))
+	copy(sorted, windows)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SuccessRate < sorted[j].SuccessRate
+	})
+	return sorted[(len(sorted)-1)/2], true
+}
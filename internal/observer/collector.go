@@ -0,0 +1,314 @@
+package observer
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/internal/rollup"
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// windowStats holds the aggregates of one dimension value within a window
+type windowStats struct {
+	Total            int64 // payments that are not excluded
+	Successful       int64
+	SuccessRate      float64
+	CustomerDropped  int64
+	GatewayRate      float64 // successful share of the payments not dropped by the customer
+	Authenticated    int64   // payments that reached each funnel stage
+	Authorized       int64
+	Captured         int64
+	TotalAmount      int64 // base amount in minor units
+	SuccessfulAmount int64
+	WeightedRate     float64 // successful share of TotalAmount, in percent
+}
+
+// valueStats holds the current and baseline windows of one dimension value
+type valueStats struct {
+	Attributes models.Attributes
+	Current    windowStats
+	Baselines  []windowStats // only the baseline windows the value had payments in
+	Fallback   windowStats   // the fallback window, when one is configured
+}
+
+// windowColumns is the number of sums collectStats selects per window
+const windowColumns = 8
+
+// collectStats aggregates the current and baseline windows of every enabled
+// dimension in a single pass over the minutely rollup. Each dimension is a
+// grouping set and each window a set of FILTERed sums; windows are widened
+// to whole minutes. The result is keyed by dimension name.
+func (o *Observer) collectStats(now time.Time) (map[string][]*valueStats, error) {
+	windows := append([]timeWindow{o.currentWindow(now)}, o.baselineWindows(now)...)
+	baselines := windows[1:]
+	if fallback, ok := o.fallbackWindow(now); ok {
+		windows = append(windows, fallback)
+	}
+
+	sets := groupingSets(o.config.Dimensions, rollup.AttributeExpr)
+	exprs, byGrouping := sets.exprs, sets.byGrouping
+	if len(exprs) == 0 {
+		return nil, nil
+	}
+
+	selects := append([]string{}, exprs...)
+	selects = append(selects, "GROUPING("+strings.Join(exprs, ", ")+") AS grouping_id")
+	var args []interface{}
+	for i, window := range windows {
+		from, to := window.buckets()
+		selects = append(selects,
+			fmt.Sprintf("COALESCE(SUM(total) FILTER (WHERE bucket >= ? AND bucket < ?), 0)::bigint AS total_%d", i),
+			fmt.Sprintf("COALESCE(SUM(successful) FILTER (WHERE bucket >= ? AND bucket < ?), 0)::bigint AS successful_%d", i),
+			fmt.Sprintf("COALESCE(SUM(customer_dropped) FILTER (WHERE bucket >= ? AND bucket < ?), 0)::bigint AS customer_dropped_%d", i),
+			fmt.Sprintf("COALESCE(SUM(authenticated) FILTER (WHERE bucket >= ? AND bucket < ?), 0)::bigint AS authenticated_%d", i),
+			fmt.Sprintf("COALESCE(SUM(authorized) FILTER (WHERE bucket >= ? AND bucket < ?), 0)::bigint AS authorized_%d", i),
+			fmt.Sprintf("COALESCE(SUM(captured) FILTER (WHERE bucket >= ? AND bucket < ?), 0)::bigint AS captured_%d", i),
+			fmt.Sprintf("COALESCE(SUM(total_amount) FILTER (WHERE bucket >= ? AND bucket < ?), 0)::bigint AS total_amount_%d", i),
+			fmt.Sprintf("COALESCE(SUM(successful_amount) FILTER (WHERE bucket >= ? AND bucket < ?), 0)::bigint AS successful_amount_%d", i))
+		for j := 0; j < windowColumns; j++ {
+			args = append(args, from, to)
+		}
+	}
+
+	var ranges []string
+	args = append(args, o.rollup.Layout())
+	for _, window := range windows {
+		from, to := window.buckets()
+		ranges = append(ranges, "(bucket >= ? AND bucket < ?)")
+		args = append(args, from, to)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE layout = ? AND (%s) GROUP BY GROUPING SETS (%s)",
+		strings.Join(selects, ", "),
+		rollup.Table,
+		strings.Join(ranges, " OR "),
+		strings.Join(sets.sets, ", "))
+
+	rows, err := o.db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Windows of every value in query order: current, baselines, fallback
+	windowed := make(map[string][]windowedValue)
+	for rows.Next() {
+		values := make([]sql.NullString, len(exprs))
+		counts := make([]int64, windowColumns*len(windows))
+		var grouping int64
+		dest := make([]interface{}, 0, len(values)+1+len(counts))
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &grouping)
+		for i := range counts {
+			dest = append(dest, &counts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		stats := make([]windowStats, len(windows))
+		for i := range windows {
+			c := counts[windowColumns*i:]
+			stats[i] = newWindowStats(c[0], c[1], c[2], c[6], c[7])
+			stats[i].Authenticated, stats[i].Authorized, stats[i].Captured = c[3], c[4], c[5]
+		}
+
+		for _, dim := range byGrouping[grouping] {
+			windowed[dim.Name] = append(windowed[dim.Name], windowedValue{
+				Attributes: sets.attributes(dim, values),
+				Windows:    stats,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	open := o.openValues()
+	collected := make(map[string][]*valueStats)
+	for _, dim := range o.config.Dimensions {
+		values := windowed[dim.Name]
+		if dim.Bounded() {
+			values = fold(dim, values, 1+len(baselines), open[dim.Name])
+		}
+		for _, v := range values {
+			value := &valueStats{Attributes: v.Attributes, Current: v.Windows[0]}
+			for _, baseline := range v.Windows[1 : 1+len(baselines)] {
+				if baseline.Total > 0 {
+					value.Baselines = append(value.Baselines, baseline)
+				}
+			}
+			if len(windows) > 1+len(baselines) {
+				value.Fallback = v.Windows[len(windows)-1]
+			}
+			collected[dim.Name] = append(collected[dim.Name], value)
+		}
+	}
+	return collected, nil
+}
+
+// windowedValue holds every window of one dimension value, aligned with the
+// windows of the query
+type windowedValue struct {
+	Attributes models.Attributes
+	Windows    []windowStats
+}
+
+// openValues returns the values that have an open incident, by dimension
+// name, keyed by openKey
+func (o *Observer) openValues() map[string]map[string]bool {
+	open := make(map[string]map[string]bool)
+	for _, alert := range o.alerts.Active() {
+		if open[alert.Dimension] == nil {
+			open[alert.Dimension] = make(map[string]bool)
+		}
+		open[alert.Dimension][openKey(alert.Attributes)] = true
+	}
+	return open
+}
+
+// openKey identifies a value by its attributes whatever their order, which
+// alerts loaded from the store need not share with the live stats
+func openKey(attrs models.Attributes) string {
+	sorted := append(models.Attributes{}, attrs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted.Key()
+}
+
+// fold keeps the values of a bounded dimension that are among its TopK and
+// reach its MinVolume, ranked by their payments in the first ranked windows,
+// and sums the rest into a single dimension.Other value so that the number
+// of values monitored stays bounded however many there are. Values with an
+// open incident are kept regardless, so that the incident can resolve.
+func fold(dim *dimension.Dimension, values []windowedValue, ranked int, open map[string]bool) []windowedValue {
+	volume := func(v windowedValue) int64 {
+		var total int64
+		for _, window := range v.Windows[:ranked] {
+			total += window.Total
+		}
+		return total
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		return volume(values[i]) > volume(values[j])
+	})
+
+	var kept []windowedValue
+	var other *windowedValue
+	for i, v := range values {
+		if open[openKey(v.Attributes)] || (dim.TopK == 0 || i < dim.TopK) && volume(v) >= int64(dim.MinVolume) {
+			kept = append(kept, v)
+			continue
+		}
+		if other == nil {
+			attrs := make(models.Attributes, len(dim.Fields))
+			for j, field := range dim.Fields {
+				attrs[j] = models.Attribute{Name: field.Name, Value: dimension.Other}
+			}
+			other = &windowedValue{Attributes: attrs, Windows: make([]windowStats, len(v.Windows))}
+		}
+		for w := range v.Windows {
+			other.Windows[w] = other.Windows[w].add(v.Windows[w])
+		}
+	}
+	if other != nil {
+		kept = append(kept, *other)
+	}
+	return kept
+}
+
+// dimensionGroupings maps the enabled dimensions onto the grouping sets of one
+// aggregate query
+type dimensionGroupings struct {
+	exprs      []string                         // one grouping column per distinct field
+	position   map[string]int                   // column of each field name
+	sets       []string                         // GROUPING SETS entries
+	byGrouping map[int64][]*dimension.Dimension // dimensions by GROUPING() value
+}
+
+// groupingSets builds the grouping sets of the given dimensions, reading each
+// field with exprOf. GROUPING() sets the bit of every column a row is not
+// grouped by, with the first column as the most significant bit. Dimensions
+// over the same fields share a grouping set.
+func groupingSets(dimensions []*dimension.Dimension, exprOf func(dimension.Field) string) dimensionGroupings {
+	g := dimensionGroupings{
+		position:   make(map[string]int),
+		byGrouping: make(map[int64][]*dimension.Dimension),
+	}
+	for _, dim := range dimensions {
+		for _, field := range dim.Fields {
+			if _, ok := g.position[field.Name]; !ok {
+				g.position[field.Name] = len(g.exprs)
+				g.exprs = append(g.exprs, exprOf(field))
+			}
+		}
+	}
+
+	for _, dim := range dimensions {
+		grouping := int64(1)<<len(g.exprs) - 1
+		for _, field := range dim.Fields {
+			grouping &^= 1 << (len(g.exprs) - 1 - g.position[field.Name])
+		}
+		if _, ok := g.byGrouping[grouping]; !ok {
+			set := make([]string, len(dim.Fields))
+			for i, field := range dim.Fields {
+				set[i] = exprOf(field)
+			}
+			g.sets = append(g.sets, "("+strings.Join(set, ", ")+")")
+		}
+		g.byGrouping[grouping] = append(g.byGrouping[grouping], dim)
+	}
+	return g
+}
+
+// attributes reads the values of a dimension's fields from a scanned row
+func (g dimensionGroupings) attributes(dim *dimension.Dimension, values []sql.NullString) models.Attributes {
+	attrs := make(models.Attributes, 0, len(dim.Fields))
+	for _, field := range dim.Fields {
+		attrs = append(attrs, models.Attribute{
+			Name:  field.Name,
+			Value: values[g.position[field.Name]].String,
+		})
+	}
+	return attrs
+}
+
+// folded reports whether attributes are those of the dimension.Other value
+func folded(attrs models.Attributes) bool {
+	return len(attrs) > 0 && attrs[0].Value == dimension.Other
+}
+
+// add returns the stats of two disjoint sets of payments in the same window
+func (w windowStats) add(other windowStats) windowStats {
+	sum := newWindowStats(w.Total+other.Total, w.Successful+other.Successful, w.CustomerDropped+other.CustomerDropped,
+		w.TotalAmount+other.TotalAmount, w.SuccessfulAmount+other.SuccessfulAmount)
+	sum.Authenticated = w.Authenticated + other.Authenticated
+	sum.Authorized = w.Authorized + other.Authorized
+	sum.Captured = w.Captured + other.Captured
+	return sum
+}
+
+func newWindowStats(total, successful, customerDropped, totalAmount, successfulAmount int64) windowStats {
+	stats := windowStats{
+		Total:            total,
+		Successful:       successful,
+		CustomerDropped:  customerDropped,
+		TotalAmount:      totalAmount,
+		SuccessfulAmount: successfulAmount,
+	}
+	if total > 0 {
+		stats.SuccessRate = float64(successful) / float64(total) * 100
+	}
+	if attempted := total - customerDropped; attempted > 0 {
+		stats.GatewayRate = float64(successful) / float64(attempted) * 100
+	}
+	if totalAmount > 0 {
+		stats.WeightedRate = float64(successfulAmount) / float64(totalAmount) * 100
+	}
+	return stats
+}
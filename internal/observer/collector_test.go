@@ -0,0 +1,77 @@
+package observer
+
+import (
+	"testing"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+func terminal(id string, total int64) windowedValue {
+	return windowedValue{
+		Attributes: models.Attributes{{Name: "terminal_id", Value: id}},
+		Windows:    []windowStats{newWindowStats(total, total/2, 0, 0, 0)},
+	}
+}
+
+func TestFoldKeepsValuesWithOpenIncidents(t *testing.T) {
+	dim := &dimension.Dimension{
+		Name:      "terminal",
+		Fields:    []dimension.Field{{Name: "terminal_id"}},
+		TopK:      1,
+		MinVolume: 10,
+	}
+	small := terminal("t2", 4)
+
+	tests := []struct {
+		name string
+		open map[string]bool
+		want []string
+	}{
+		{"no open incident", nil, []string{"t1", dimension.Other}},
+		{"open incident below the bounds", map[string]bool{openKey(small.Attributes): true}, []string{"t1", "t2", dimension.Other}},
+		{"incident resolved", map[string]bool{}, []string{"t1", dimension.Other}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := []windowedValue{terminal("t3", 6), small, terminal("t1", 100)}
+			kept := fold(dim, values, 1, tt.open)
+
+			var got []string
+			for _, v := range kept {
+				got = append(got, v.Attributes[0].Value)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("fold kept %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("fold kept %v, want %v", got, tt.want)
+				}
+			}
+			other := kept[len(kept)-1].Windows[0].Total
+			if want := 110 - sumKept(kept); other != want {
+				t.Errorf("%s holds %d payments, want %d", dimension.Other, other, want)
+			}
+		})
+	}
+}
+
+func sumKept(kept []windowedValue) int64 {
+	var total int64
+	for _, v := range kept[:len(kept)-1] {
+		total += v.Windows[0].Total
+	}
+	return total
+}
+
+func TestOpenKeyIgnoresAttributeOrder(t *testing.T) {
+	live := models.Attributes{{Name: "gateway", Value: "hdfc"}, {Name: "method", Value: "card"}}
+	loaded := models.Attributes{{Name: "method", Value: "card"}, {Name: "gateway", Value: "hdfc"}}
+	if openKey(live) != openKey(loaded) {
+		t.Errorf("openKey(%s) = %s, want %s", loaded, openKey(loaded), openKey(live))
+	}
+	if live.Key() != (models.Attributes{{Name: "gateway", Value: "hdfc"}, {Name: "method", Value: "card"}}).Key() {
+		t.Errorf("openKey changed the order of the attributes it was given")
+	}
+}
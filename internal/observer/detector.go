@@ -0,0 +1,122 @@
+package observer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// DetectorType selects how the observer decides that a dimension value is
+// anomalous
+type DetectorType string
+
+const (
+	// DetectorThreshold compares a value with its baseline windows and alerts
+	// on drops past the configured thresholds
+	DetectorThreshold DetectorType = "threshold"
+	// DetectorForecast learns the success rate and volume a value has at each
+	// time of day and week, and alerts on residuals outside a learned band
+	DetectorForecast DetectorType = "forecast"
+)
+
+// ParseDetectorType validates a detector name from config. An empty name
+// selects the threshold detector.
+func ParseDetectorType(name string) (DetectorType, error) {
+	switch detector := DetectorType(name); detector {
+	case "":
+		return DetectorThreshold, nil
+	case DetectorThreshold, DetectorForecast:
+		return detector, nil
+	default:
+		return "", fmt.Errorf("unknown detector: %s", name)
+	}
+}
+
+// Detector judges the success rate and volume of dimension values
+type Detector interface {
+	// SuccessRate judges the success rate of a value in the current window
+	SuccessRate(dim *dimension.Dimension, stat *models.PaymentStats) Verdict
+	// Volume judges the payment count of a value in the current window,
+	// including values that had none
+	Volume(dim *dimension.Dimension, value *valueStats, now time.Time) Verdict
+	// Learn is called with every value of a dimension after they are judged
+	Learn(dim *dimension.Dimension, values []*valueStats, now time.Time)
+}
+
+// restorer is implemented by detectors that persist what they learn. Restore
+// reloads the state, which another replica may have moved on since.
+type restorer interface {
+	Restore() error
+}
+
+// Verdict is a detector's judgement of one value
+type Verdict struct {
+	Anomalous bool
+	Skip      bool             // too little data or evidence to judge; open incidents stay open
+	Rule      string           // threshold rule applied
+	Threshold float64          // limit the value was judged against
+	PValue    float64          // volume only; success-rate p-values are set on the stats
+	Expected  float64          // volume only: payments expected in the current window
+	Silent    bool             // volume only: the value had steady volume and has none now
+	Forecast  *models.Forecast // forecast detector only
+}
+
+// thresholdDetector compares values with their baseline windows
+type thresholdDetector struct {
+	o *Observer
+}
+
+func (d thresholdDetector) SuccessRate(dim *dimension.Dimension, stat *models.PaymentStats) Verdict {
+	if !stat.HasBaseline {
+		return Verdict{Skip: true}
+	}
+	limits := d.o.thresholdsFor(dim.Name, stat.Attributes)
+	if stat.Total < limits.MinTransactions {
+		return Verdict{Skip: true}
+	}
+	fmt.Println("current drop percentage", stat.DropPercentage)
+	fmt.Println("threshold ", limits.Drop, "rule", limits.Rule)
+
+	verdict := Verdict{Rule: limits.Rule, Threshold: limits.limit(d.o.config.ThresholdBasis)}
+	if !d.o.breached(stat, limits) {
+		return verdict
+	}
+	if !d.o.testSignificance(stat) && d.o.requiresSignificance() {
+		fmt.Printf("suppressing insignificant drop for %s %s: p-value %.4f\n", dim.Name, stat.Attributes, stat.PValue)
+		verdict.Skip = true
+		return verdict
+	}
+	verdict.Anomalous = true
+	return verdict
+}
+
+// Volume compares the volume of a value with what its baseline predicts. A
+// value that had payments in every baseline window and has none now is
+// silent.
+func (d thresholdDetector) Volume(dim *dimension.Dimension, value *valueStats, now time.Time) Verdict {
+	baselineTotal, ok := medianTotal(value.Baselines)
+	if !ok || baselineTotal < int64(d.o.config.MinBaselineVolume) {
+		return Verdict{Skip: true}
+	}
+	current := value.Current.Total
+	verdict := Verdict{
+		Expected: float64(baselineTotal) * float64(d.o.config.CurrentWindow) / float64(d.o.config.BaselineWindow),
+		Silent:   current == 0 && len(value.Baselines) == len(d.o.baselineWindows(now)),
+	}
+	if (verdict.Expected-float64(current))/verdict.Expected*100 <= d.o.config.VolumeDropThreshold {
+		return verdict
+	}
+	verdict.PValue = poissonPValue(current, verdict.Expected)
+	if d.o.config.Significance != SignificanceNone && verdict.PValue >= 1-d.o.config.Confidence {
+		fmt.Printf("suppressing insignificant volume drop for %s %s: p-value %.4f\n", dim.Name, value.Attributes, verdict.PValue)
+		verdict.Skip = true
+		return verdict
+	}
+	verdict.Anomalous = true
+	return verdict
+}
+
+// Learn is a no-op: the threshold detector learns nothing
+func (d thresholdDetector) Learn(*dimension.Dimension, []*valueStats, time.Time) {}
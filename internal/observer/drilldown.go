@@ -0,0 +1,151 @@
+package observer
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/internal/outcome"
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// drillDown breaks the failures behind an alert down by each configured child
+// attribute the alert's dimension does not already group by, and ranks the
+// child values by how much of the drop they explain.
+//
+// A child value explains the failures it has beyond what its own baseline rate
+// predicts for its current volume; values without a baseline are held to the
+// alert's baseline rate. Shares are relative to the total excess failures of
+// the same child attribute.
+func (o *Observer) drillDown(dim *dimension.Dimension, alert *models.Alert, now time.Time) ([]models.Contribution, error) {
+	var children []dimension.Field
+	for _, child := range o.config.DrillDown {
+		if !dim.HasField(child.Name) {
+			children = append(children, child)
+		}
+	}
+	if len(children) == 0 {
+		return nil, nil
+	}
+
+	exprs := make([]string, len(children))
+	sets := make([]string, len(children))
+	for i, child := range children {
+		exprs[i] = child.Expr
+		sets[i] = "(" + child.Expr + ")"
+	}
+
+	windows := o.rawWindows(now)
+	counted := o.config.Outcomes.Counted()
+	succeeded := o.config.Outcomes.Is(outcome.Success)
+	filter, filterArgs := attributeFilter(dim, alert.Attributes)
+
+	var args []interface{}
+	args = append(args, windows.currentArgs...)
+	args = append(args, windows.currentArgs...)
+	args = append(args, windows.baselineArgs...)
+	args = append(args, windows.baselineArgs...)
+	args = append(args, filterArgs...)
+	args = append(args, windows.currentArgs...)
+	args = append(args, windows.baselineArgs...)
+
+	query := fmt.Sprintf(`SELECT %s, GROUPING(%s),
+	COUNT(*) FILTER (WHERE %s),
+	COUNT(*) FILTER (WHERE %s AND %s),
+	COUNT(*) FILTER (WHERE %s),
+	COUNT(*) FILTER (WHERE %s AND %s)
+FROM payments
+WHERE %s AND %s AND (%s OR %s)
+GROUP BY GROUPING SETS (%s)`,
+		strings.Join(exprs, ", "), strings.Join(exprs, ", "),
+		windows.current,
+		windows.current, succeeded,
+		windows.baseline,
+		windows.baseline, succeeded,
+		counted, filter, windows.current, windows.baseline,
+		strings.Join(sets, ", "))
+
+	rows, err := o.db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byChild := make(map[string][]models.Contribution)
+	for rows.Next() {
+		values := make([]sql.NullString, len(children))
+		var grouping, total, successful, baselineTotal, baselineSuccessful int64
+		dest := make([]interface{}, 0, len(values)+5)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &grouping, &total, &successful, &baselineTotal, &baselineSuccessful)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if total == 0 {
+			continue
+		}
+
+		// The one column the row is grouped by has its GROUPING bit cleared
+		child := -1
+		for i := range children {
+			if grouping&(1<<(len(children)-1-i)) == 0 {
+				child = i
+				break
+			}
+		}
+		if child < 0 {
+			continue
+		}
+
+		baselineRate := alert.PreviousRate / 100
+		if baselineTotal > 0 {
+			baselineRate = float64(baselineSuccessful) / float64(baselineTotal)
+		}
+		contribution := models.Contribution{
+			Attribute:      children[child].Name,
+			Value:          values[child].String,
+			Total:          int(total),
+			SuccessRate:    float64(successful) / float64(total) * 100,
+			BaselineRate:   baselineRate * 100,
+			ExcessFailures: float64(total)*baselineRate - float64(successful),
+		}
+		byChild[contribution.Attribute] = append(byChild[contribution.Attribute], contribution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var ranked []models.Contribution
+	for _, contributions := range byChild {
+		var excess float64
+		for _, contribution := range contributions {
+			if contribution.ExcessFailures > 0 {
+				excess += contribution.ExcessFailures
+			}
+		}
+		if excess == 0 {
+			continue
+		}
+		for _, contribution := range contributions {
+			if contribution.ExcessFailures > 0 {
+				contribution.Share = contribution.ExcessFailures / excess * 100
+				ranked = append(ranked, contribution)
+			}
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Share != ranked[j].Share {
+			return ranked[i].Share > ranked[j].Share
+		}
+		return ranked[i].ExcessFailures > ranked[j].ExcessFailures
+	})
+	if len(ranked) > o.config.DrillDownTopN {
+		ranked = ranked[:o.config.DrillDownTopN]
+	}
+	return ranked, nil
+}
@@ -0,0 +1,103 @@
+package observer
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/internal/outcome"
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// errorFields are the keys of the payments error document the breakdown
+// groups failures by
+var errorFields = []string{"code", "reason"}
+
+// errorBreakdown counts the failed payments behind an alert by error code and
+// reason in the current and baseline windows. For each field the most
+// frequent current values are kept, along with any value that did not occur
+// in the baseline at all. Baseline counts cover all baseline windows
+// together, so shares are the comparable figure when there are several.
+func (o *Observer) errorBreakdown(dim *dimension.Dimension, alert *models.Alert, now time.Time) ([]models.ErrorCount, error) {
+	windows := o.rawWindows(now)
+	filter, filterArgs := attributeFilter(dim, alert.Attributes)
+
+	var args []interface{}
+	args = append(args, windows.currentArgs...)
+	args = append(args, windows.baselineArgs...)
+	args = append(args, filterArgs...)
+	args = append(args, windows.currentArgs...)
+	args = append(args, windows.baselineArgs...)
+
+	query := fmt.Sprintf(`SELECT COALESCE("error"->>'code', 'UNKNOWN'), COALESCE("error"->>'reason', 'UNKNOWN'),
+	GROUPING("error"->>'code', "error"->>'reason'),
+	COUNT(*) FILTER (WHERE %s),
+	COUNT(*) FILTER (WHERE %s)
+FROM payments
+WHERE %s AND %s AND (%s OR %s)
+GROUP BY GROUPING SETS (("error"->>'code'), ("error"->>'reason'))`,
+		windows.current, windows.baseline,
+		o.config.Outcomes.Is(outcome.GatewayFailure, outcome.CustomerDropOff),
+		filter, windows.current, windows.baseline)
+
+	rows, err := o.db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byField := make(map[string][]models.ErrorCount)
+	currentTotals := make(map[string]int)
+	baselineTotals := make(map[string]int)
+	for rows.Next() {
+		var code, reason sql.NullString
+		var grouping, current, baseline int64
+		if err := rows.Scan(&code, &reason, &grouping, &current, &baseline); err != nil {
+			return nil, err
+		}
+
+		// GROUPING has the bit of the column a row is not grouped by set
+		count := models.ErrorCount{Field: "code", Value: code.String, Current: int(current), Baseline: int(baseline)}
+		if grouping&2 != 0 {
+			count.Field = "reason"
+			count.Value = reason.String
+		}
+		count.New = current > 0 && baseline == 0
+		byField[count.Field] = append(byField[count.Field], count)
+		currentTotals[count.Field] += count.Current
+		baselineTotals[count.Field] += count.Baseline
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var breakdown []models.ErrorCount
+	for _, field := range errorFields {
+		counts := byField[field]
+		for i := range counts {
+			if total := currentTotals[field]; total > 0 {
+				counts[i].CurrentShare = float64(counts[i].Current) / float64(total) * 100
+			}
+			if total := baselineTotals[field]; total > 0 {
+				counts[i].BaselineShare = float64(counts[i].Baseline) / float64(total) * 100
+			}
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			if counts[i].Current != counts[j].Current {
+				return counts[i].Current > counts[j].Current
+			}
+			return counts[i].Value < counts[j].Value
+		})
+		for i, count := range counts {
+			if count.Current == 0 {
+				break
+			}
+			if i < o.config.ErrorsTopN || count.New {
+				breakdown = append(breakdown, count)
+			}
+		}
+	}
+	return breakdown, nil
+}
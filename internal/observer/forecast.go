@@ -0,0 +1,234 @@
+package observer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Forecast metrics
+const (
+	metricSuccessRate = "success_rate"
+	metricVolume      = "volume"
+)
+
+type ForecastConfig struct {
+	Location          *time.Location // time zone of the daily and weekly seasons
+	Band              float64        // standard deviations of residual that alert
+	WarmUp            int            // observations before a model is trusted; until then the threshold detector judges
+	LevelSmoothing    float64        // weight of a new observation in the level and the residual variance
+	SeasonalSmoothing float64        // weight of a new observation in the daily and weekly seasons
+}
+
+// seasonalModel is an additive Holt-Winters model with a daily and a weekly
+// season: the forecast for a time is the level plus the offset of its hour of
+// the day plus the offset of its hour of the week from the daily profile.
+// The variance of the residuals sets the width of the band.
+type seasonalModel struct {
+	Level        float64      `json:"level"`
+	Daily        [24]float64  `json:"daily"`
+	Weekly       [168]float64 `json:"weekly"`
+	Variance     float64      `json:"variance"`
+	Observations int          `json:"observations"`
+	LearnedAt    time.Time    `json:"learned_at"`
+}
+
+func (m *seasonalModel) slots(at time.Time, loc *time.Location) (int, int) {
+	at = at.In(loc)
+	return at.Hour(), int(at.Weekday())*24 + at.Hour()
+}
+
+func (m *seasonalModel) forecast(at time.Time, loc *time.Location) float64 {
+	hour, week := m.slots(at, loc)
+	return m.Level + m.Daily[hour] + m.Weekly[week]
+}
+
+func (m *seasonalModel) learn(y float64, at time.Time, config *ForecastConfig) {
+	if m.Observations == 0 {
+		m.Level = y
+	} else {
+		residual := y - m.forecast(at, config.Location)
+		m.Variance += config.LevelSmoothing * (residual*residual - m.Variance)
+
+		hour, week := m.slots(at, config.Location)
+		m.Level += config.LevelSmoothing * (y - m.Daily[hour] - m.Weekly[week] - m.Level)
+		m.Daily[hour] += config.SeasonalSmoothing * (y - m.Level - m.Weekly[week] - m.Daily[hour])
+		m.Weekly[week] += config.SeasonalSmoothing * (y - m.Level - m.Daily[hour] - m.Weekly[week])
+	}
+	m.Observations++
+}
+
+// forecastDetector judges values by how far they fall below what their
+// models forecast. Values whose models are still warming up are judged by
+// the threshold detector.
+type forecastDetector struct {
+	o        *Observer
+	db       *gorm.DB // nil keeps the models in memory only
+	fallback Detector
+	models   map[string]*seasonalModel
+}
+
+func newForecastDetector(o *Observer, db *gorm.DB) *forecastDetector {
+	return &forecastDetector{
+		o:        o,
+		db:       db,
+		fallback: thresholdDetector{o},
+		models:   make(map[string]*seasonalModel),
+	}
+}
+
+func forecastKey(metric string, dim *dimension.Dimension, attrs models.Attributes) string {
+	return metric + "|" + dim.Name + "|" + attrs.Key()
+}
+
+// Restore reloads every persisted model
+func (d *forecastDetector) Restore() error {
+	if d.db == nil {
+		return nil
+	}
+	var states []models.ForecastState
+	if err := d.db.Find(&states).Error; err != nil {
+		return err
+	}
+	restored := make(map[string]*seasonalModel, len(states))
+	for _, state := range states {
+		model := &seasonalModel{}
+		if err := json.Unmarshal(state.State, model); err != nil {
+			return fmt.Errorf("forecast state %s: %v", state.Key, err)
+		}
+		restored[state.Key] = model
+	}
+	d.models = restored
+	return nil
+}
+
+// trusted returns the model of a metric once it has warmed up
+func (d *forecastDetector) trusted(metric string, dim *dimension.Dimension, attrs models.Attributes) *seasonalModel {
+	model := d.models[forecastKey(metric, dim, attrs)]
+	if model == nil || model.Observations < d.o.config.Forecast.WarmUp {
+		return nil
+	}
+	return model
+}
+
+// at is the time the current window is forecast for, its middle
+func (d *forecastDetector) at(now time.Time) time.Time {
+	return now.Add(-d.o.config.CurrentWindow / 2)
+}
+
+// SuccessRate alerts when the success rate falls below the band. The band is
+// at least as wide as the sampling error of the rate at the current volume.
+func (d *forecastDetector) SuccessRate(dim *dimension.Dimension, stat *models.PaymentStats) Verdict {
+	model := d.trusted(metricSuccessRate, dim, stat.Attributes)
+	if model == nil {
+		return d.fallback.SuccessRate(dim, stat)
+	}
+	if stat.Total < d.o.thresholdsFor(dim.Name, stat.Attributes).MinTransactions {
+		return Verdict{Skip: true}
+	}
+
+	expected := math.Max(0, math.Min(100, model.forecast(d.at(stat.Timestamp), d.o.config.Forecast.Location)))
+	p := expected / 100
+	sampling := math.Sqrt(p*(1-p)/float64(stat.Total)) * 100
+	forecast := d.band(metricSuccessRate, stat.SuccessRate, expected, math.Max(math.Sqrt(model.Variance), sampling))
+	return Verdict{
+		Anomalous: forecast.Actual < forecast.Lower,
+		Rule:      string(DetectorForecast),
+		Threshold: d.o.config.Forecast.Band,
+		Forecast:  forecast,
+	}
+}
+
+// Volume alerts when the payment count falls below the band. The band is at
+// least as wide as the Poisson noise of the expected count.
+func (d *forecastDetector) Volume(dim *dimension.Dimension, value *valueStats, now time.Time) Verdict {
+	model := d.trusted(metricVolume, dim, value.Attributes)
+	if model == nil {
+		return d.fallback.Volume(dim, value, now)
+	}
+	expected := math.Max(0, model.forecast(d.at(now), d.o.config.Forecast.Location))
+	if expected == 0 || expected < float64(d.o.config.MinBaselineVolume) {
+		return Verdict{Skip: true}
+	}
+
+	current := value.Current.Total
+	forecast := d.band(metricVolume, float64(current), expected, math.Max(math.Sqrt(model.Variance), math.Sqrt(expected)))
+	return Verdict{
+		Anomalous: forecast.Actual < forecast.Lower,
+		Threshold: d.o.config.Forecast.Band,
+		PValue:    poissonPValue(current, expected),
+		Expected:  expected,
+		Silent:    current == 0,
+		Forecast:  forecast,
+	}
+}
+
+func (d *forecastDetector) band(metric string, actual, expected, deviation float64) *models.Forecast {
+	width := d.o.config.Forecast.Band * deviation
+	forecast := &models.Forecast{
+		Metric:   metric,
+		Actual:   actual,
+		Expected: expected,
+		Lower:    expected - width,
+		Upper:    expected + width,
+	}
+	if deviation > 0 {
+		forecast.Deviation = (actual - expected) / deviation
+	}
+	return forecast
+}
+
+// Learn updates the models of every value with the current window, once per
+// current window so that observations do not overlap. Success rates are only
+// learned from windows with enough payments to be meaningful. The updated
+// models are persisted.
+func (d *forecastDetector) Learn(dim *dimension.Dimension, values []*valueStats, now time.Time) {
+	var states []models.ForecastState
+	learn := func(metric string, attrs models.Attributes, y float64) {
+		key := forecastKey(metric, dim, attrs)
+		model := d.models[key]
+		if model == nil {
+			model = &seasonalModel{}
+			d.models[key] = model
+		}
+		if now.Sub(model.LearnedAt) < d.o.config.CurrentWindow {
+			return
+		}
+		model.learn(y, d.at(now), &d.o.config.Forecast)
+		model.LearnedAt = now
+
+		state, err := json.Marshal(model)
+		if err != nil {
+			fmt.Printf("Error encoding forecast state %s: %v\n", key, err)
+			return
+		}
+		states = append(states, models.ForecastState{
+			Key:       key,
+			Dimension: dim.Name,
+			Value:     attrs.Label(),
+			Metric:    metric,
+			State:     state,
+			UpdatedAt: now,
+		})
+	}
+
+	for _, value := range values {
+		learn(metricVolume, value.Attributes, float64(value.Current.Total))
+		if value.Current.Total >= int64(d.o.config.MinTransactions) && value.Current.Total > 0 {
+			learn(metricSuccessRate, value.Attributes, value.Current.SuccessRate)
+		}
+	}
+
+	if d.db == nil || len(states) == 0 {
+		return
+	}
+	if err := d.db.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(states, 500).Error; err != nil {
+		fmt.Printf("Error persisting forecast states of %s: %v\n", dim.Name, err)
+	}
+}
@@ -0,0 +1,50 @@
+package observer
+
+import "github.com/yourusername/payment-monitor/pkg/models"
+
+// Funnel stages, in the order payments pass them. A 3DS or OTP failure stops
+// a payment at authentication and an issuer decline at authorization; they
+// have different owners, so alerts name the stage that regressed.
+const (
+	StageAuthentication = "authentication"
+	StageAuthorization  = "authorization"
+	StageCapture        = "capture"
+)
+
+// funnel returns the stage-wise conversion of the current window against the
+// baseline. Each stage is entered by the payments that passed the one before
+// it, and the first by every counted payment. Stages the baseline has no
+// payments in have no drop.
+func funnel(current, baseline windowStats) []models.FunnelStage {
+	stage := func(name string, entered, passed, baselineEntered, baselinePassed int64) models.FunnelStage {
+		s := models.FunnelStage{Stage: name, Entered: int(entered), Passed: int(passed)}
+		if entered > 0 {
+			s.Conversion = float64(passed) / float64(entered) * 100
+		}
+		if baselineEntered > 0 {
+			s.BaselineConversion = float64(baselinePassed) / float64(baselineEntered) * 100
+			if entered > 0 {
+				s.Drop = s.BaselineConversion - s.Conversion
+			}
+		}
+		return s
+	}
+	return []models.FunnelStage{
+		stage(StageAuthentication, current.Total, current.Authenticated, baseline.Total, baseline.Authenticated),
+		stage(StageAuthorization, current.Authenticated, current.Authorized, baseline.Authenticated, baseline.Authorized),
+		stage(StageCapture, current.Authorized, current.Captured, baseline.Authorized, baseline.Captured),
+	}
+}
+
+// regressedStage names the stage whose conversion dropped the most, or ""
+// when none dropped
+func regressedStage(stages []models.FunnelStage) string {
+	var regressed string
+	var drop float64
+	for _, s := range stages {
+		if s.Drop > drop {
+			regressed, drop = s.Stage, s.Drop
+		}
+	}
+	return regressed
+}
@@ -0,0 +1,233 @@
+package observer
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// latencyMetric is a payment stage whose duration is monitored
+type latencyMetric struct {
+	Name  string
+	Delta string // duration of the stage in seconds
+	Valid string // condition for the stage to have completed
+}
+
+var latencyMetrics = []latencyMetric{
+	{
+		Name:  "authorization",
+		Delta: "authorized_at - created_at",
+		Valid: "authorized_at > 0 AND authorized_at >= created_at",
+	},
+	{
+		Name:  "capture",
+		Delta: "captured_at - authorized_at",
+		Valid: "captured_at > 0 AND authorized_at > 0 AND captured_at >= authorized_at",
+	},
+}
+
+// latencyPercentiles are the percentiles reported for every stage
+var latencyPercentiles = []float64{0.5, 0.95, 0.99}
+
+// latencyDue reports whether the latency check should run on this tick.
+// Percentiles are computed from raw payments, so they run on their own,
+// usually longer, interval.
+func (o *Observer) latencyDue(now time.Time) bool {
+	if !o.config.LatencyDetection {
+		return false
+	}
+	if now.Sub(o.lastLatencyCheck) < o.config.LatencyInterval {
+		return false
+	}
+	o.lastLatencyCheck = now
+	return true
+}
+
+// checkLatency compares the stage latency percentiles of every dimension value
+// with its baseline and alerts on regressions of the configured percentile.
+// With several baseline windows the percentiles are taken over all of them
+// together.
+func (o *Observer) checkLatency(now time.Time) {
+	collected, err := o.collectLatency(now)
+	if err != nil {
+		fmt.Printf("Error collecting payment latency: %v\n", err)
+		return
+	}
+
+	for _, dim := range o.config.Dimensions {
+		for _, value := range collected[dim.Name] {
+			// Bounded dimensions only monitor the values that were not folded
+			if dim.Bounded() && !o.monitored[dim.Name][value.Attributes.Key()] {
+				continue
+			}
+			fingerprint := models.AlertFingerprint(models.AlertTypeLatency, dim.Name, value.Attributes)
+
+			var regressed, skipped bool
+			for i := range value.Latency {
+				verdict := o.latencyRegressed(&value.Latency[i])
+				regressed = regressed || verdict.Anomalous
+				skipped = skipped || verdict.Skip
+			}
+			if !regressed {
+				// A stage with too few payments to judge may still be regressed
+				if !skipped {
+					o.resolve(fingerprint, now)
+				}
+				continue
+			}
+
+			fmt.Printf("latency alert for dimension %s %s\n", dim.Name, value.Attributes)
+			alert := &models.Alert{
+				Type:        models.AlertTypeLatency,
+				Fingerprint: fingerprint,
+				Dimension:   dim.Name,
+				Value:       value.Attributes.Label(),
+				Attributes:  value.Attributes,
+				Baseline:    o.baselineLabel(),
+				HasBaseline: true,
+				Latency:     value.Latency,
+				Timestamp:   now,
+				Gateway:     value.Attributes.Get("gateway"),
+				Method:      value.Attributes.Get("method"),
+				MerchantID:  value.Attributes.Get("merchant_id"),
+			}
+			o.fire(dim, alert)
+		}
+	}
+}
+
+// latencyRegressed fills in the increase of the monitored percentile and
+// judges whether it is a regression. A stage with too few completions in
+// either window is skipped, unless it had none in both and does not apply.
+// The increase must pass both the relative and the absolute minimum; a
+// baseline of zero seconds is held to the absolute minimum only.
+func (o *Observer) latencyRegressed(stats *models.LatencyStats) Verdict {
+	stats.Percentile = o.config.LatencyPercentile
+	current, baseline := stats.P95, stats.BaselineP95
+	switch o.config.LatencyPercentile {
+	case 50:
+		current, baseline = stats.P50, stats.BaselineP50
+	case 99:
+		current, baseline = stats.P99, stats.BaselineP99
+	}
+
+	stats.Increase = current - baseline
+	if baseline > 0 {
+		stats.IncreasePercentage = stats.Increase / baseline * 100
+	}
+	if stats.Count == 0 && stats.BaselineCount == 0 {
+		// The stage does not apply to the value's payments
+		return Verdict{}
+	}
+	if stats.Count < o.config.MinTransactions || stats.BaselineCount < o.config.MinTransactions {
+		return Verdict{Skip: true}
+	}
+	if stats.Increase < o.config.MinLatencyIncrease.Seconds() {
+		return Verdict{}
+	}
+	stats.Regressed = baseline == 0 || stats.IncreasePercentage > o.config.LatencyIncrease
+	return Verdict{Anomalous: stats.Regressed}
+}
+
+// latencyValue holds the stage latencies of one dimension value
+type latencyValue struct {
+	Attributes models.Attributes
+	Latency    []models.LatencyStats
+}
+
+// collectLatency computes the stage latency percentiles of every enabled
+// dimension in the current and baseline windows, in a single pass over raw
+// payments grouped like collectStats. The result is keyed by dimension name.
+func (o *Observer) collectLatency(now time.Time) (map[string][]*latencyValue, error) {
+	sets := groupingSets(o.config.Dimensions, rawExpr)
+	if len(sets.exprs) == 0 {
+		return nil, nil
+	}
+	windows := o.rawWindows(now)
+
+	selects := append([]string{}, sets.exprs...)
+	selects = append(selects, "GROUPING("+strings.Join(sets.exprs, ", ")+")")
+	var args []interface{}
+	for _, metric := range latencyMetrics {
+		for _, window := range []struct {
+			cond string
+			args []interface{}
+		}{{windows.current, windows.currentArgs}, {windows.baseline, windows.baselineArgs}} {
+			filter := fmt.Sprintf("FILTER (WHERE %s AND %s)", window.cond, metric.Valid)
+			selects = append(selects, "COUNT(*) "+filter)
+			args = append(args, window.args...)
+			for _, p := range latencyPercentiles {
+				selects = append(selects, fmt.Sprintf("percentile_cont(%g) WITHIN GROUP (ORDER BY %s) %s", p, metric.Delta, filter))
+				args = append(args, window.args...)
+			}
+		}
+	}
+	args = append(args, windows.currentArgs...)
+	args = append(args, windows.baselineArgs...)
+
+	query := fmt.Sprintf("SELECT %s FROM payments WHERE %s OR %s GROUP BY GROUPING SETS (%s)",
+		strings.Join(selects, ", "),
+		windows.current, windows.baseline,
+		strings.Join(sets.sets, ", "))
+
+	rows, err := o.db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collected := make(map[string][]*latencyValue)
+	for rows.Next() {
+		values := make([]sql.NullString, len(sets.exprs))
+		var grouping int64
+		counts := make([]int64, 2*len(latencyMetrics))
+		percentiles := make([]sql.NullFloat64, 2*len(latencyMetrics)*len(latencyPercentiles))
+
+		dest := make([]interface{}, 0, len(values)+1+len(counts)+len(percentiles))
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &grouping)
+		// Per metric: current count and percentiles, then baseline count and
+		// percentiles
+		for w := 0; w < 2*len(latencyMetrics); w++ {
+			dest = append(dest, &counts[w])
+			for p := range latencyPercentiles {
+				dest = append(dest, &percentiles[w*len(latencyPercentiles)+p])
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		latency := make([]models.LatencyStats, len(latencyMetrics))
+		for m, metric := range latencyMetrics {
+			current, baseline := 2*m, 2*m+1
+			at := func(w, p int) float64 { return percentiles[w*len(latencyPercentiles)+p].Float64 }
+			latency[m] = models.LatencyStats{
+				Metric:        metric.Name,
+				Count:         int(counts[current]),
+				BaselineCount: int(counts[baseline]),
+				P50:           at(current, 0),
+				P95:           at(current, 1),
+				P99:           at(current, 2),
+				BaselineP50:   at(baseline, 0),
+				BaselineP95:   at(baseline, 1),
+				BaselineP99:   at(baseline, 2),
+			}
+		}
+
+		for _, dim := range sets.byGrouping[grouping] {
+			stats := make([]models.LatencyStats, len(latency))
+			copy(stats, latency)
+			collected[dim.Name] = append(collected[dim.Name], &latencyValue{
+				Attributes: sets.attributes(dim, values),
+				Latency:    stats,
+			})
+		}
+	}
+	return collected, rows.Err()
+}
@@ -3,35 +3,187 @@ package observer
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sort"
 	"time"
 
+	"github.com/yourusername/payment-monitor/internal/alerting"
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/internal/outcome"
+	"github.com/yourusername/payment-monitor/internal/rollup"
+	"github.com/yourusername/payment-monitor/internal/rules"
+	"github.com/yourusername/payment-monitor/internal/slo"
 	"github.com/yourusername/payment-monitor/internal/websocket"
 	"github.com/yourusername/payment-monitor/pkg/models"
 	"gorm.io/gorm"
 )
 
+// pruneInterval is how often the observer deletes rollup rows no window reads
+const pruneInterval = time.Hour
+
 type Observer struct {
 	db           *gorm.DB
 	config       *Config
 	alertChannel chan<- *models.Alert
 	hub          *websocket.Hub
+	alerts       *alerting.Manager
+	rollup       *rollup.Rollup
+
+	lastLatencyCheck time.Time
+	lastRefundCheck  time.Time
+	lastSLOCheck     time.Time
+	lastPrune        time.Time
+	leading          bool // whether the last tick ran as the leader
+	detector         Detector
+	monitored        map[string]map[string]bool // values of bounded dimensions not folded, by dimension
+}
+
+// Leadership tells whether this replica is the one observing
+type Leadership interface {
+	IsLeader() bool
 }
 
 type Config struct {
-	Interval        time.Duration
-	Threshold       float64
-	MinTransactions int
-	Dimensions      []string
+	Leader           Leadership // nil when this is the only replica
+	Interval         time.Duration
+	Threshold        float64
+	ThresholdBasis   ThresholdBasis
+	LostGMVThreshold float64         // minor units of the base currency, for ThresholdLostGMV
+	FallbackWindow   time.Duration   // compared against by values without baseline data
+	SuccessRateFloor float64         // compared against when the fallback window has no data either
+	ThresholdRules   []ThresholdRule // overrides of the thresholds above, first match wins
+	MinTransactions  int
+	Dimensions       []*dimension.Dimension
+	Outcomes         *outcome.Taxonomy // must match the rollup's
+	MaxMetricValues  int               // values per dimension broadcast on each tick
+	CurrentWindow    time.Duration
+	BaselineWindow   time.Duration
+	Baseline         BaselineStrategy
+	BaselineWeeks    int
+	Significance     SignificanceMethod
+	Confidence       float64
+	DrillDown        []dimension.Field // child attributes to break firing alerts down by
+	DrillDownTopN    int
+	ErrorsTopN       int // error codes and reasons kept per alert, besides new ones
+
+	VolumeDetection     bool
+	VolumeDropThreshold float64 // percent below the expected volume that alerts
+	MinBaselineVolume   int     // baseline payments a value needs for volume alerts
+
+	LatencyDetection   bool
+	LatencyInterval    time.Duration
+	LatencyPercentile  int           // 50, 95 or 99
+	LatencyIncrease    float64       // percent over the baseline percentile that alerts
+	MinLatencyIncrease time.Duration // smallest increase that alerts
+
+	RefundDetection     bool
+	RefundInterval      time.Duration
+	RefundDimensions    []*dimension.Dimension // checked for refund and dispute spikes
+	RefundRateIncrease  float64                // percentage points over the baseline refund rate that alert
+	DisputeRateIncrease float64                // percentage points over the baseline dispute rate that alert
+	MinCapturedVolume   int                    // captured payments a value needs in both windows
+
+	Rules []*rules.Rule // alert rules evaluated against the stats of every dimension value
+
+	SLO *slo.Tracker // nil when no objectives are declared
+
+	Detector DetectorType
+	Forecast ForecastConfig // for DetectorForecast
 }
 
-func NewObserver(db *gorm.DB, config *Config, alertChannel chan<- *models.Alert, hub *websocket.Hub) *Observer {
-	return &Observer{
+func NewObserver(db *gorm.DB, config *Config, alertChannel chan<- *models.Alert, hub *websocket.Hub, alerts *alerting.Manager, rollup *rollup.Rollup) *Observer {
+	if config.CurrentWindow == 0 {
+		config.CurrentWindow = time.Hour
+	}
+	if config.BaselineWindow == 0 {
+		config.BaselineWindow = config.CurrentWindow
+	}
+	if config.Baseline == "" {
+		config.Baseline = BaselinePreviousWindow
+	}
+	if config.BaselineWeeks == 0 {
+		config.BaselineWeeks = 4
+	}
+	if config.ThresholdBasis == "" {
+		config.ThresholdBasis = ThresholdCount
+	}
+	if config.Significance == "" {
+		config.Significance = SignificanceZTest
+	}
+	if config.Outcomes == nil {
+		config.Outcomes = outcome.Default()
+	}
+	if config.MaxMetricValues == 0 {
+		config.MaxMetricValues = 50
+	}
+	if config.Confidence == 0 {
+		config.Confidence = 0.95
+	}
+	if config.DrillDownTopN == 0 {
+		config.DrillDownTopN = 5
+	}
+	if config.ErrorsTopN == 0 {
+		config.ErrorsTopN = 5
+	}
+	if config.VolumeDropThreshold == 0 {
+		config.VolumeDropThreshold = 50
+	}
+	if config.LatencyInterval == 0 {
+		config.LatencyInterval = 5 * time.Minute
+	}
+	if config.LatencyPercentile == 0 {
+		config.LatencyPercentile = 95
+	}
+	if config.LatencyIncrease == 0 {
+		config.LatencyIncrease = 50
+	}
+	if config.RefundInterval == 0 {
+		config.RefundInterval = 5 * time.Minute
+	}
+	if config.RefundRateIncrease == 0 {
+		config.RefundRateIncrease = 2
+	}
+	if config.DisputeRateIncrease == 0 {
+		config.DisputeRateIncrease = 0.5
+	}
+	if config.Detector == "" {
+		config.Detector = DetectorThreshold
+	}
+	if config.Forecast.Location == nil {
+		config.Forecast.Location = time.UTC
+	}
+	if config.Forecast.Band == 0 {
+		config.Forecast.Band = 3
+	}
+	if config.Forecast.WarmUp == 0 {
+		// A week of hourly windows visits every slot of the weekly season
+		config.Forecast.WarmUp = 168
+	}
+	if config.Forecast.LevelSmoothing == 0 {
+		config.Forecast.LevelSmoothing = 0.1
+	}
+	if config.Forecast.SeasonalSmoothing == 0 {
+		config.Forecast.SeasonalSmoothing = 0.2
+	}
+	o := &Observer{
 		db:           db,
 		config:       config,
 		alertChannel: alertChannel,
 		hub:          hub,
+		alerts:       alerts,
+		rollup:       rollup,
+		monitored:    make(map[string]map[string]bool),
+	}
+	o.detector = o.newDetector(db)
+	return o
+}
+
+// newDetector creates the configured detector. A nil db keeps what the
+// detector learns in memory.
+func (o *Observer) newDetector(db *gorm.DB) Detector {
+	if o.config.Detector == DetectorForecast {
+		return newForecastDetector(o, db)
 	}
+	return thresholdDetector{o}
 }
 
 func (o *Observer) Start(ctx context.Context) {
@@ -43,301 +195,298 @@ func (o *Observer) Start(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if o.config.Leader != nil {
+				if !o.config.Leader.IsLeader() {
+					o.leading = false
+					continue
+				}
+				if err := o.alerts.Sync(); err != nil {
+					fmt.Printf("Error syncing alerts: %v\n", err)
+				}
+			}
+			if !o.leading {
+				// Another replica may have learned since this one last led
+				if r, ok := o.detector.(restorer); ok {
+					if err := r.Restore(); err != nil {
+						fmt.Printf("Error restoring detector state: %v\n", err)
+					}
+				}
+				o.leading = true
+			}
 			fmt.Println("observer observing")
-			o.checkDimensions()
+			now := time.Now()
+			if err := o.rollup.Update(now, o.lookback(now)); err != nil {
+				fmt.Printf("Error updating payment rollup: %v\n", err)
+				continue
+			}
+			if now.Sub(o.lastPrune) >= pruneInterval {
+				// Nothing older than the oldest window read is kept
+				if err := o.rollup.Prune(now, o.lookback(now)); err != nil {
+					fmt.Printf("Error pruning payment rollup: %v\n", err)
+				}
+				o.lastPrune = now
+			}
+			o.checkDimensions(now)
 		}
 	}
 }
 
-func (o *Observer) checkDimensions() {
-	for _, dimension := range o.config.Dimensions {
-		fmt.Println("checking dimension", dimension)
-		stats, err := o.getPaymentStats(dimension)
-		if err != nil {
-			fmt.Printf("Error getting stats for dimension %s: %v\n", dimension, err)
-			continue
+// Replay steps a simulated clock from from to to, checking the dimensions as
+// the observer would have at each step. The rollup is backfilled for the
+// whole range first and is not updated while stepping, so that a replay
+// never moves the watermark of a live observer.
+func (o *Observer) Replay(from, to time.Time, step time.Duration) error {
+	if step <= 0 {
+		return fmt.Errorf("replay step must be positive")
+	}
+	if err := o.rollup.Backfill(from.Add(-o.lookback(from)), to); err != nil {
+		return err
+	}
+	// Models learn from the replayed range alone and are not persisted
+	o.detector = o.newDetector(nil)
+	for now := from; !now.After(to); now = now.Add(step) {
+		o.checkDimensions(now)
+	}
+	return nil
+}
+
+// checkDimensions compares every dimension value with its baseline as of now
+// and raises or resolves alerts
+func (o *Observer) checkDimensions(now time.Time) {
+	collected, err := o.collectStats(now)
+	if err != nil {
+		fmt.Printf("Error collecting payment stats: %v\n", err)
+		return
+	}
+
+	for _, dim := range o.config.Dimensions {
+		fmt.Println("checking dimension", dim.Name)
+		stats := o.buildStats(dim, collected[dim.Name], now)
+		if dim.Bounded() {
+			monitored := make(map[string]bool, len(stats))
+			for _, stat := range stats {
+				monitored[stat.Attributes.Key()] = true
+			}
+			o.monitored[dim.Name] = monitored
 		}
+		o.broadcastMetrics(stats)
 
 		for _, stat := range stats {
-			// Send metrics through WebSocket
-			o.hub.BroadcastMetrics(&websocket.MetricsMessage{
-				Type:        "metrics",
-				Dimension:   stat.Dimension,
-				Value:       stat.Value,
-				SuccessRate: stat.SuccessRate,
-				Timestamp:   stat.Timestamp,
-			})
-
-			if stat.Total < o.config.MinTransactions {
+			verdict := o.detector.SuccessRate(dim, stat)
+			if verdict.Skip {
 				continue
 			}
-			fmt.Println("current drop percentage", stat.DropPercentage)
-			fmt.Println("threshold ", o.config.Threshold)
-			if stat.DropPercentage > o.config.Threshold {
-				fmt.Println(stat)
-				fmt.Printf("alerting for dimension %s drop %f\n", dimension, stat.DropPercentage)
-				alert := &models.Alert{
-					ID:             fmt.Sprintf("%s-%s-%d", dimension, stat.Value, time.Now().Unix()),
-					Dimension:      dimension,
-					Value:          stat.Value,
-					CurrentRate:    stat.SuccessRate,
-					PreviousRate:   stat.PreviousRate,
-					DropPercentage: stat.DropPercentage,
-					Timestamp:      time.Now(),
-				}
+			fingerprint := models.AlertFingerprint(models.AlertTypeSuccessRate, dim.Name, stat.Attributes)
+			if !verdict.Anomalous {
+				o.resolve(fingerprint, stat.Timestamp)
+				continue
+			}
+			fmt.Println(stat)
+			fmt.Printf("alerting for dimension %s drop %f stage %s\n", dim.Name, stat.DropPercentage, stat.Stage)
+			alert := &models.Alert{
+				Type:           models.AlertTypeSuccessRate,
+				Fingerprint:    fingerprint,
+				Dimension:      dim.Name,
+				Value:          stat.Value,
+				Attributes:     stat.Attributes,
+				CurrentRate:    stat.SuccessRate,
+				PreviousRate:   stat.PreviousRate,
+				Baseline:       stat.Baseline,
+				HasBaseline:    stat.HasBaseline,
+				NewValue:       stat.NewValue,
+				DropPercentage: stat.DropPercentage,
+				PValue:         stat.PValue,
+				IntervalLow:    stat.IntervalLow,
+				IntervalHigh:   stat.IntervalHigh,
+				CurrentTotal:   stat.Total,
+
+				CustomerDropped:       stat.CustomerDropped,
+				GatewayRate:           stat.GatewayRate,
+				PreviousGatewayRate:   stat.PreviousGatewayRate,
+				GatewayDropPercentage: stat.GatewayDropPercentage,
+
+				WeightedRate:           stat.WeightedRate,
+				PreviousWeightedRate:   stat.PreviousWeightedRate,
+				WeightedDropPercentage: stat.WeightedDropPercentage,
+				LostGMV:                stat.LostGMV,
+
+				Stage:  stat.Stage,
+				Funnel: stat.Funnel,
+
+				ThresholdRule: verdict.Rule,
+				Threshold:     verdict.Threshold,
+				Forecast:      verdict.Forecast,
+				Timestamp:     stat.Timestamp,
+			}
 
-				// Add dimension-specific fields
-				switch dimension {
-				case "gateway":
-					fmt.Println("gateway alert triggered for ", stat.Value)
-					alert.Gateway = stat.Value
-				case "gateway_method":
-					// Split the value into gateway and method
-					parts := strings.Split(stat.Value, "_")
-					if len(parts) == 2 {
-						alert.Gateway = parts[0]
-						alert.Method = parts[1]
-					}
-					fmt.Println("gateway method alert triggered for gateway", alert.Gateway, " method ", alert.Method)
-				case "gateway_merchant":
-					// Split the value into gateway and merchant_id
-					parts := strings.Split(stat.Value, "_")
-					if len(parts) == 2 {
-						alert.Gateway = parts[0]
-						alert.MerchantID = parts[1]
-					}
-					fmt.Println("gateway merchant alert triggered for gateway", alert.Gateway, " merchantID ", alert.MerchantID)
-				}
+			// Add dimension-specific fields
+			alert.Gateway = stat.Attributes.Get("gateway")
+			alert.Method = stat.Attributes.Get("method")
+			alert.MerchantID = stat.Attributes.Get("merchant_id")
+			fmt.Println(dim.Name, "alert triggered for", stat.Attributes)
 
-				o.alertChannel <- alert
-			}
+			o.fire(dim, alert)
 		}
-	}
-}
 
-func (o *Observer) getPaymentStats(dimension string) ([]*models.PaymentStats, error) {
-	now := time.Now()
-	oneHourAgo := now.Add(-1 * time.Hour)
-	twoHoursAgo := now.Add(-2 * time.Hour)
-
-	switch dimension {
-	case "gateway":
-		return o.getGatewayStats(oneHourAgo, twoHoursAgo)
-	case "gateway_method":
-		return o.getGatewayMethodStats(oneHourAgo, twoHoursAgo)
-	case "gateway_merchant":
-		return o.getGatewayMerchantStats(oneHourAgo, twoHoursAgo)
-	default:
-		return nil, fmt.Errorf("unknown dimension: %s", dimension)
+		o.checkRules(dim, stats)
+		o.checkVolume(dim, collected[dim.Name], now)
+		o.detector.Learn(dim, collected[dim.Name], now)
 	}
-}
 
-func (o *Observer) getGatewayStats(oneHourAgo, twoHoursAgo time.Time) ([]*models.PaymentStats, error) {
-	var currentStats []struct {
-		Gateway     string
-		Total       int64
-		Successful  int64
-		SuccessRate float64
+	if o.latencyDue(now) {
+		o.checkLatency(now)
 	}
-
-	// Get current hour stats
-	if err := o.db.Model(&models.Payment{}).
-		Select("gateway, COUNT(*) as total, "+
-			"SUM(CASE WHEN status = 'STATUS_CAPTURED' THEN 1 ELSE 0 END) as successful, "+
-			"AVG(CASE WHEN status = 'STATUS_CAPTURED' THEN 1.0 ELSE 0.0 END) * 100 as success_rate").
-		Where("to_timestamp(created_at) >= to_timestamp(?)", oneHourAgo.Unix()).
-		Group("gateway").
-		Scan(&currentStats).Error; err != nil {
-		return nil, err
+	if o.refundsDue(now) {
+		o.checkRefunds(now)
 	}
-
-	fmt.Println("currentStats", currentStats)
-
-	var previousStats []struct {
-		Gateway     string
-		SuccessRate float64
+	if o.sloDue(now) {
+		o.checkSLOs(now)
 	}
+	o.settle(now)
+}
 
-	// Get previous hour stats
-	if err := o.db.Model(&models.Payment{}).
-		Select("gateway, AVG(CASE WHEN status = 'STATUS_CAPTURED' THEN 1.0 ELSE 0.0 END) * 100 as success_rate").
-		Where("to_timestamp(created_at) >= to_timestamp(?) AND to_timestamp(created_at) < to_timestamp(?)",
-			twoHoursAgo.Unix(), oneHourAgo.Unix()).
-		Group("gateway").
-		Scan(&previousStats).Error; err != nil {
-		return nil, err
+// broadcastMetrics sends the success rates of a dimension's values through
+// the WebSocket hub, at most MaxMetricValues of them by volume so that the
+// stream stays bounded however many values a dimension has. Replays have no
+// hub.
+func (o *Observer) broadcastMetrics(stats []*models.PaymentStats) {
+	if o.hub == nil {
+		return
 	}
-	fmt.Println("previousStats", previousStats)
-
-	// Combine stats
-	stats := make([]*models.PaymentStats, 0, len(currentStats))
-	for _, current := range currentStats {
-		previousRate := findPreviousRate(previousStats, current.Gateway)
-		dropPercentage := previousRate - current.SuccessRate // Show positive drop percentage
-
-		stats = append(stats, &models.PaymentStats{
-			Dimension:      "gateway",
-			Value:          current.Gateway,
-			Total:          int(current.Total),
-			Successful:     int(current.Successful),
-			SuccessRate:    current.SuccessRate,
-			PreviousRate:   previousRate,
-			DropPercentage: dropPercentage,
-			Timestamp:      time.Now(),
+	if len(stats) > o.config.MaxMetricValues {
+		stats = append([]*models.PaymentStats{}, stats...)
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].Total > stats[j].Total })
+		stats = stats[:o.config.MaxMetricValues]
+	}
+	for _, stat := range stats {
+		o.hub.BroadcastMetrics(&websocket.MetricsMessage{
+			Type:        "metrics",
+			Dimension:   stat.Dimension,
+			Value:       stat.Value,
+			Attributes:  stat.Attributes,
+			SuccessRate: stat.SuccessRate,
+			HasBaseline: stat.HasBaseline,
+			NewValue:    stat.NewValue,
+			Timestamp:   stat.Timestamp,
 		})
 	}
-
-	return stats, nil
 }
 
-func findPreviousRate(stats []struct {
-	Gateway     string
-	SuccessRate float64
-}, gateway string) float64 {
-	for _, stat := range stats {
-		if stat.Gateway == gateway {
-			return stat.SuccessRate
+// fire hands a breach to the alert manager and notifies when it decides to.
+// Notified alerts are first broken down by error, except for refund and
+// dispute spikes, and, for success-rate drops, by the drill-down attributes.
+func (o *Observer) fire(dim *dimension.Dimension, alert *models.Alert) {
+	notify := o.alerts.Fire(alert, alert.Timestamp)
+	if notify == nil {
+		return
+	}
+	if folded(notify.Attributes) {
+		// The folded values cannot be selected by attribute to break down
+		o.alertChannel <- notify
+		return
+	}
+
+	// Excess failures only explain success-rate drops
+	if notify.Type == models.AlertTypeSuccessRate {
+		contributions, err := o.drillDown(dim, notify, notify.Timestamp)
+		if err != nil {
+			fmt.Printf("Error drilling down alert %s: %v\n", notify.ID, err)
+		} else {
+			notify.DrillDown = contributions
 		}
 	}
-	return 0.0
+	// Refunds and disputes are of captured payments, which have no errors
+	if notify.Type != models.AlertTypeRefundRate && notify.Type != models.AlertTypeDisputeRate {
+		errors, err := o.errorBreakdown(dim, notify, notify.Timestamp)
+		if err != nil {
+			fmt.Printf("Error breaking down errors of alert %s: %v\n", notify.ID, err)
+		} else {
+			notify.Errors = errors
+		}
+	}
+	o.alerts.Annotate(notify)
+	o.alertChannel <- notify
 }
 
-func (o *Observer) getGatewayMethodStats(oneHourAgo, twoHoursAgo time.Time) ([]*models.PaymentStats, error) {
-	var currentStats []struct {
-		Gateway     string
-		Method      string
-		Total       int64
-		Successful  int64
-		SuccessRate float64
-	}
-
-	// Get current hour stats
-	if err := o.db.Model(&models.Payment{}).
-		Select("gateway, method, COUNT(*) as total, "+
-			"SUM(CASE WHEN status = 'STATUS_CAPTURED' THEN 1 ELSE 0 END) as successful, "+
-			"AVG(CASE WHEN status = 'STATUS_CAPTURED' THEN 1.0 ELSE 0.0 END) * 100 as success_rate").
-		Where("to_timestamp(created_at) >= to_timestamp(?)", oneHourAgo.Unix()).
-		Group("gateway, method").
-		Scan(&currentStats).Error; err != nil {
-		return nil, err
-	}
-
-	var previousStats []struct {
-		Gateway     string
-		Method      string
-		SuccessRate float64
-	}
-
-	// Get previous hour stats
-	if err := o.db.Model(&models.Payment{}).
-		Select("gateway, method, AVG(CASE WHEN status = 'STATUS_CAPTURED' THEN 1.0 ELSE 0.0 END) * 100 as success_rate").
-		Where("to_timestamp(created_at) >= to_timestamp(?) AND to_timestamp(created_at) < to_timestamp(?)",
-			twoHoursAgo.Unix(), oneHourAgo.Unix()).
-		Group("gateway, method").
-		Scan(&previousStats).Error; err != nil {
-		return nil, err
-	}
-
-	// Combine stats
-	stats := make([]*models.PaymentStats, 0, len(currentStats))
-	for _, current := range currentStats {
-		previousRate := findPreviousRateForGatewayMethod(previousStats, current.Gateway, current.Method)
-		dropPercentage := previousRate - current.SuccessRate // Show positive drop percentage
-
-		stats = append(stats, &models.PaymentStats{
-			Dimension:      "gateway_method",
-			Value:          fmt.Sprintf("%s_%s", current.Gateway, current.Method),
-			Total:          int(current.Total),
-			Successful:     int(current.Successful),
-			SuccessRate:    current.SuccessRate,
-			PreviousRate:   previousRate,
-			DropPercentage: dropPercentage,
-			Timestamp:      time.Now(),
-		})
+// resolve notifies about an open incident whose value has recovered
+func (o *Observer) resolve(fingerprint string, now time.Time) {
+	if notify := o.alerts.Resolve(fingerprint, now); notify != nil {
+		fmt.Println("alert resolved", notify.ID)
+		o.alertChannel <- notify
 	}
-
-	return stats, nil
 }
 
-func (o *Observer) getGatewayMerchantStats(oneHourAgo, twoHoursAgo time.Time) ([]*models.PaymentStats, error) {
-	var currentStats []struct {
-		Gateway     string
-		MerchantID  string
-		Total       int64
-		Successful  int64
-		SuccessRate float64
-	}
-
-	// Get current hour stats
-	if err := o.db.Model(&models.Payment{}).
-		Select("gateway, merchant_id, COUNT(*) as total, "+
-			"SUM(CASE WHEN status = 'STATUS_CAPTURED' THEN 1 ELSE 0 END) as successful, "+
-			"AVG(CASE WHEN status = 'STATUS_CAPTURED' THEN 1.0 ELSE 0.0 END) * 100 as success_rate").
-		Where("to_timestamp(created_at) >= to_timestamp(?)", oneHourAgo.Unix()).
-		Group("gateway, merchant_id").
-		Scan(&currentStats).Error; err != nil {
-		return nil, err
-	}
-
-	var previousStats []struct {
-		Gateway     string
-		MerchantID  string
-		SuccessRate float64
-	}
-
-	// Get previous hour stats
-	if err := o.db.Model(&models.Payment{}).
-		Select("gateway, merchant_id, AVG(CASE WHEN status = 'STATUS_CAPTURED' THEN 1.0 ELSE 0.0 END) * 100 as success_rate").
-		Where("to_timestamp(created_at) >= to_timestamp(?) AND to_timestamp(created_at) < to_timestamp(?)",
-			twoHoursAgo.Unix(), oneHourAgo.Unix()).
-		Group("gateway, merchant_id").
-		Scan(&previousStats).Error; err != nil {
-		return nil, err
-	}
-
-	// Combine stats
-	stats := make([]*models.PaymentStats, 0, len(currentStats))
-	for _, current := range currentStats {
-		previousRate := findPreviousRateForGatewayMerchant(previousStats, current.Gateway, current.MerchantID)
-		dropPercentage := previousRate - current.SuccessRate // Show positive drop percentage
-
-		stats = append(stats, &models.PaymentStats{
-			Dimension:      "gateway_merchant",
-			Value:          fmt.Sprintf("%s_%s", current.Gateway, current.MerchantID),
-			Total:          int(current.Total),
-			Successful:     int(current.Successful),
-			SuccessRate:    current.SuccessRate,
-			PreviousRate:   previousRate,
-			DropPercentage: dropPercentage,
-			Timestamp:      time.Now(),
-		})
+// settle resolves the incidents no check has confirmed for the expiry, and
+// notifies about the recoveries that have lasted for the cooldown
+func (o *Observer) settle(now time.Time) {
+	o.alerts.Expire(now)
+	for _, notify := range o.alerts.Settle(now) {
+		fmt.Println("alert resolved", notify.ID)
+		o.alertChannel <- notify
 	}
-
-	return stats, nil
 }
 
-func findPreviousRateForGatewayMethod(stats []struct {
-	Gateway     string
-	Method      string
-	SuccessRate float64
-}, gateway, method string) float64 {
-	for _, stat := range stats {
-		if stat.Gateway == gateway && stat.Method == method {
-			return stat.SuccessRate
+// buildStats compares the current window of each value of a dimension with
+// its baseline
+func (o *Observer) buildStats(dim *dimension.Dimension, values []*valueStats, now time.Time) []*models.PaymentStats {
+	stats := make([]*models.PaymentStats, 0, len(values))
+	for _, value := range values {
+		current := value.Current
+		if current.Total == 0 {
+			continue
 		}
-	}
-	return 0.0
-}
 
-func findPreviousRateForGatewayMerchant(stats []struct {
-	Gateway     string
-	MerchantID  string
-	SuccessRate float64
-}, gateway, merchantID string) float64 {
-	for _, stat := range stats {
-		if stat.Gateway == gateway && stat.MerchantID == merchantID {
-			return stat.SuccessRate
+		previous, label, newValue, hasBaseline := o.resolveBaseline(value)
+		previousRate := previous.SuccessRate
+		dropPercentage := previousRate - current.SuccessRate // Show positive drop percentage
+		previousGatewayRate := previous.GatewayRate
+		gatewayDropPercentage := previousGatewayRate - current.GatewayRate
+		previousWeightedRate := previous.WeightedRate
+		weightedDropPercentage := previousWeightedRate - current.WeightedRate
+		// What the current volume would have captured at the baseline weighted rate
+		lostGMV := float64(current.TotalAmount)*previous.WeightedRate/100 - float64(current.SuccessfulAmount)
+		if lostGMV < 0 {
+			lostGMV = 0
 		}
+		if !hasBaseline {
+			// Nothing to compare with; report no drop rather than a negative one
+			previousRate, dropPercentage = 0, 0
+			previousGatewayRate, gatewayDropPercentage = 0, 0
+			previousWeightedRate, weightedDropPercentage, lostGMV = 0, 0, 0
+		}
+
+		stages := funnel(current, previous)
+
+		stats = append(stats, &models.PaymentStats{
+			Dimension:               dim.Name,
+			Value:                   value.Attributes.Label(),
+			Attributes:              value.Attributes,
+			Total:                   int(current.Total),
+			Successful:              int(current.Successful),
+			SuccessRate:             current.SuccessRate,
+			PreviousTotal:           int(previous.Total),
+			PreviousSuccessful:      int(previous.Successful),
+			PreviousRate:            previousRate,
+			Baseline:                label,
+			HasBaseline:             hasBaseline,
+			NewValue:                newValue,
+			DropPercentage:          dropPercentage,
+			CustomerDropped:         int(current.CustomerDropped),
+			PreviousCustomerDropped: int(previous.CustomerDropped),
+			GatewayRate:             current.GatewayRate,
+			PreviousGatewayRate:     previousGatewayRate,
+			GatewayDropPercentage:   gatewayDropPercentage,
+			TotalAmount:             current.TotalAmount,
+			SuccessfulAmount:        current.SuccessfulAmount,
+			WeightedRate:            current.WeightedRate,
+			PreviousWeightedRate:    previousWeightedRate,
+			WeightedDropPercentage:  weightedDropPercentage,
+			LostGMV:                 lostGMV,
+			Funnel:                  stages,
+			Stage:                   regressedStage(stages),
+			Timestamp:               now,
+		})
 	}
-	return 0.0
+
+	return stats
 }
@@ -0,0 +1,59 @@
+package observer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// rawWindows holds the conditions selecting the current and baseline windows
+// from raw payments, for the enrichment queries that cannot be answered from
+// the rollup
+type rawWindows struct {
+	current      string
+	currentArgs  []interface{}
+	baseline     string
+	baselineArgs []interface{}
+}
+
+func (o *Observer) rawWindows(now time.Time) rawWindows {
+	return o.rawWindowsOf(now, "created_at")
+}
+
+// rawWindowsOf selects the windows by another unix-seconds column of
+// payments, e.g. refunded_at
+func (o *Observer) rawWindowsOf(now time.Time, column string) rawWindows {
+	current := o.currentWindow(now)
+	windows := rawWindows{
+		current:     fmt.Sprintf("(%s >= ? AND %s < ?)", column, column),
+		currentArgs: []interface{}{current.From.Unix(), current.To.Unix()},
+	}
+
+	var ranges []string
+	for _, window := range o.baselineWindows(now) {
+		ranges = append(ranges, fmt.Sprintf("(%s >= ? AND %s < ?)", column, column))
+		windows.baselineArgs = append(windows.baselineArgs, window.From.Unix(), window.To.Unix())
+	}
+	windows.baseline = "(" + strings.Join(ranges, " OR ") + ")"
+	return windows
+}
+
+// rawExpr reads a dimension field from a row of payments
+func rawExpr(field dimension.Field) string {
+	return field.Expr
+}
+
+// attributeFilter returns the condition selecting the payments of one value of
+// a dimension
+func attributeFilter(dim *dimension.Dimension, attrs models.Attributes) (string, []interface{}) {
+	filters := make([]string, len(dim.Fields))
+	args := make([]interface{}, len(dim.Fields))
+	for i, field := range dim.Fields {
+		filters[i] = fmt.Sprintf("COALESCE(%s, '') = ?", field.Expr)
+		args[i] = attrs.Get(field.Name)
+	}
+	return strings.Join(filters, " AND "), args
+}
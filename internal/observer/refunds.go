@@ -0,0 +1,213 @@
+package observer
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// refundedCondition selects refunded payments, whether or not the refund has
+// settled yet
+const refundedCondition = "(amount_refunded > 0 OR COALESCE(refund_status, '') <> '')"
+
+// refundsDue reports whether the refund and dispute check should run on this
+// tick. Like latency it reads raw payments, so it runs on its own interval.
+func (o *Observer) refundsDue(now time.Time) bool {
+	if !o.config.RefundDetection || len(o.config.RefundDimensions) == 0 {
+		return false
+	}
+	if now.Sub(o.lastRefundCheck) < o.config.RefundInterval {
+		return false
+	}
+	o.lastRefundCheck = now
+	return true
+}
+
+// checkRefunds compares the refund and dispute rates of every value of the
+// refund dimensions with their baseline and alerts on spikes. Each rate has
+// its own alert type, so a value can have both incidents open at once.
+func (o *Observer) checkRefunds(now time.Time) {
+	collected, err := o.collectRefunds(now)
+	if err != nil {
+		fmt.Printf("Error collecting refunds and disputes: %v\n", err)
+		return
+	}
+
+	for _, dim := range o.config.RefundDimensions {
+		for _, value := range collected[dim.Name] {
+			for _, check := range []struct {
+				alertType models.AlertType
+				spike     models.RateSpike
+				threshold float64
+			}{
+				{models.AlertTypeRefundRate, value.Refunds, o.config.RefundRateIncrease},
+				{models.AlertTypeDisputeRate, value.Disputes, o.config.DisputeRateIncrease},
+			} {
+				spike := check.spike
+				fingerprint := models.AlertFingerprint(check.alertType, dim.Name, value.Attributes)
+				if !o.spiked(&spike, check.threshold) {
+					o.resolve(fingerprint, now)
+					continue
+				}
+
+				pValue := spikePValue(&spike)
+				if o.config.Significance != SignificanceNone && pValue >= 1-o.config.Confidence {
+					fmt.Printf("suppressing insignificant %s spike for %s %s: p-value %.4f\n", spike.Metric, dim.Name, value.Attributes, pValue)
+					continue
+				}
+
+				fmt.Printf("%s alert for dimension %s %s: %.2f%% vs %.2f%%\n", check.alertType, dim.Name, value.Attributes, spike.Rate, spike.BaselineRate)
+				alert := &models.Alert{
+					Type:         check.alertType,
+					Fingerprint:  fingerprint,
+					Dimension:    dim.Name,
+					Value:        value.Attributes.Label(),
+					Attributes:   value.Attributes,
+					Baseline:     o.baselineLabel(),
+					HasBaseline:  true,
+					PValue:       pValue,
+					CurrentTotal: spike.Captured,
+					Spike:        &spike,
+					Threshold:    check.threshold,
+					Timestamp:    now,
+					Gateway:      value.Attributes.Get("gateway"),
+					Method:       value.Attributes.Get("method"),
+					MerchantID:   value.Attributes.Get("merchant_id"),
+				}
+				o.fire(dim, alert)
+			}
+		}
+	}
+}
+
+// spiked fills in the increase of a rate and reports whether it crosses the
+// threshold. Both windows need enough captured payments for the rates to be
+// comparable.
+func (o *Observer) spiked(spike *models.RateSpike, threshold float64) bool {
+	spike.Increase = spike.Rate - spike.BaselineRate
+	if spike.Captured < o.config.MinCapturedVolume || spike.BaselineCaptured < o.config.MinCapturedVolume {
+		return false
+	}
+	return spike.Captured > 0 && spike.BaselineCaptured > 0 && spike.Increase > threshold
+}
+
+// spikePValue tests a rise in events as a drop in the share of captures
+// without one. Refunds of earlier captures can outnumber the captures of a
+// window, which counts as none without.
+func spikePValue(spike *models.RateSpike) float64 {
+	without := func(events, captured int) int {
+		if events > captured {
+			return 0
+		}
+		return captured - events
+	}
+	return zTestPValue(without(spike.BaselineEvents, spike.BaselineCaptured), spike.BaselineCaptured,
+		without(spike.Events, spike.Captured), spike.Captured)
+}
+
+// refundValue holds the refund and dispute rates of one dimension value
+type refundValue struct {
+	Attributes models.Attributes
+	Refunds    models.RateSpike
+	Disputes   models.RateSpike
+}
+
+// collectRefunds computes the refund and dispute rates of every refund
+// dimension in the current and baseline windows, in a single pass over raw
+// payments. Refunds are counted in the window they were made in, against the
+// payments captured in the same window. Payments carry no dispute time, so
+// disputes are counted against the window their payment was captured in;
+// recent captures have had less time to be disputed, which errs towards
+// fewer alerts. The result is keyed by dimension name.
+func (o *Observer) collectRefunds(now time.Time) (map[string][]*refundValue, error) {
+	sets := groupingSets(o.config.RefundDimensions, rawExpr)
+	if len(sets.exprs) == 0 {
+		return nil, nil
+	}
+	captured := o.rawWindowsOf(now, "captured_at")
+	refunded := o.rawWindowsOf(now, "refunded_at")
+
+	selects := append([]string{}, sets.exprs...)
+	selects = append(selects, "GROUPING("+strings.Join(sets.exprs, ", ")+")")
+	var args []interface{}
+	for _, window := range []struct {
+		captured, refunded         string
+		capturedArgs, refundedArgs []interface{}
+	}{
+		{captured.current, refunded.current, captured.currentArgs, refunded.currentArgs},
+		{captured.baseline, refunded.baseline, captured.baselineArgs, refunded.baselineArgs},
+	} {
+		selects = append(selects,
+			fmt.Sprintf("COUNT(*) FILTER (WHERE %s)", window.captured),
+			fmt.Sprintf("COUNT(*) FILTER (WHERE %s AND %s)", window.refunded, refundedCondition),
+			fmt.Sprintf("COUNT(*) FILTER (WHERE %s AND disputed = 1)", window.captured))
+		args = append(args, window.capturedArgs...)
+		args = append(args, window.refundedArgs...)
+		args = append(args, window.capturedArgs...)
+	}
+	args = append(args, captured.currentArgs...)
+	args = append(args, captured.baselineArgs...)
+	args = append(args, refunded.currentArgs...)
+	args = append(args, refunded.baselineArgs...)
+
+	query := fmt.Sprintf("SELECT %s FROM payments WHERE %s OR %s OR %s OR %s GROUP BY GROUPING SETS (%s)",
+		strings.Join(selects, ", "),
+		captured.current, captured.baseline, refunded.current, refunded.baseline,
+		strings.Join(sets.sets, ", "))
+
+	rows, err := o.db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collected := make(map[string][]*refundValue)
+	for rows.Next() {
+		values := make([]sql.NullString, len(sets.exprs))
+		var grouping int64
+		var counts [6]int64
+		dest := make([]interface{}, 0, len(values)+1+len(counts))
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &grouping)
+		for i := range counts {
+			dest = append(dest, &counts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		// Per window: captured, refunded and disputed
+		refunds := newRateSpike("refund", counts[1], counts[0], counts[4], counts[3])
+		disputes := newRateSpike("dispute", counts[2], counts[0], counts[5], counts[3])
+		for _, dim := range sets.byGrouping[grouping] {
+			collected[dim.Name] = append(collected[dim.Name], &refundValue{
+				Attributes: sets.attributes(dim, values),
+				Refunds:    refunds,
+				Disputes:   disputes,
+			})
+		}
+	}
+	return collected, rows.Err()
+}
+
+func newRateSpike(metric string, events, captured, baselineEvents, baselineCaptured int64) models.RateSpike {
+	spike := models.RateSpike{
+		Metric:           metric,
+		Events:           int(events),
+		Captured:         int(captured),
+		BaselineEvents:   int(baselineEvents),
+		BaselineCaptured: int(baselineCaptured),
+	}
+	if captured > 0 {
+		spike.Rate = float64(events) / float64(captured) * 100
+	}
+	if baselineCaptured > 0 {
+		spike.BaselineRate = float64(baselineEvents) / float64(baselineCaptured) * 100
+	}
+	return spike
+}
@@ -0,0 +1,69 @@
+package observer
+
+import (
+	"fmt"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// checkRules evaluates the alert rules against the stats of every value of a
+// dimension. Rules stand apart from the drop threshold: they see every value,
+// with or without a baseline, and each rule is an incident of its own that
+// resolves once the rule stops matching.
+func (o *Observer) checkRules(dim *dimension.Dimension, stats []*models.PaymentStats) {
+	if len(o.config.Rules) == 0 {
+		return
+	}
+	for _, stat := range stats {
+		if stat.HasBaseline {
+			// Rules may refer to the p-value
+			o.testSignificance(stat)
+		}
+		for _, rule := range o.config.Rules {
+			fingerprint := models.RuleFingerprint(rule.Name, dim.Name, stat.Attributes)
+			if !rule.Match(stat) {
+				o.resolve(fingerprint, stat.Timestamp)
+				continue
+			}
+
+			fmt.Printf("rule %s (%s) matched dimension %s %s\n", rule.Name, rule.Severity, dim.Name, stat.Attributes)
+			o.fire(dim, &models.Alert{
+				Type:           models.AlertTypeRule,
+				Fingerprint:    fingerprint,
+				Dimension:      dim.Name,
+				Value:          stat.Value,
+				Attributes:     stat.Attributes,
+				CurrentRate:    stat.SuccessRate,
+				PreviousRate:   stat.PreviousRate,
+				Baseline:       stat.Baseline,
+				HasBaseline:    stat.HasBaseline,
+				NewValue:       stat.NewValue,
+				DropPercentage: stat.DropPercentage,
+				PValue:         stat.PValue,
+				IntervalLow:    stat.IntervalLow,
+				IntervalHigh:   stat.IntervalHigh,
+				CurrentTotal:   stat.Total,
+
+				CustomerDropped:       stat.CustomerDropped,
+				GatewayRate:           stat.GatewayRate,
+				PreviousGatewayRate:   stat.PreviousGatewayRate,
+				GatewayDropPercentage: stat.GatewayDropPercentage,
+
+				WeightedRate:           stat.WeightedRate,
+				PreviousWeightedRate:   stat.PreviousWeightedRate,
+				WeightedDropPercentage: stat.WeightedDropPercentage,
+				LostGMV:                stat.LostGMV,
+
+				Stage:  stat.Stage,
+				Funnel: stat.Funnel,
+
+				Rule:       rule.Record(),
+				Timestamp:  stat.Timestamp,
+				Gateway:    stat.Attributes.Get("gateway"),
+				Method:     stat.Attributes.Get("method"),
+				MerchantID: stat.Attributes.Get("merchant_id"),
+			})
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package observer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// SignificanceMethod selects the test a drop must pass before it is alerted on
+type SignificanceMethod string
+
+const (
+	// SignificanceNone alerts on the threshold alone
+	SignificanceNone SignificanceMethod = "none"
+	// SignificanceZTest requires a one-sided two-proportion z-test to reject
+	// "the current rate is not lower than the baseline"
+	SignificanceZTest SignificanceMethod = "z_test"
+	// SignificanceWilson requires the baseline rate to lie above the Wilson
+	// interval of the current rate
+	SignificanceWilson SignificanceMethod = "wilson"
+)
+
+// ParseSignificanceMethod validates a method name from config. An empty name
+// selects the z-test.
+func ParseSignificanceMethod(name string) (SignificanceMethod, error) {
+	switch method := SignificanceMethod(name); method {
+	case "":
+		return SignificanceZTest, nil
+	case SignificanceNone, SignificanceZTest, SignificanceWilson:
+		return method, nil
+	default:
+		return "", fmt.Errorf("unknown significance method: %s", name)
+	}
+}
+
+// testSignificance fills in the p-value and confidence interval of the stat
+// and reports whether its drop is significant under the configured method,
+// on the rate the threshold basis compares
+func (o *Observer) testSignificance(stat *models.PaymentStats) bool {
+	total, previousTotal, previousRate := stat.Total, stat.PreviousTotal, stat.PreviousRate
+	if o.config.ThresholdBasis == ThresholdGateway {
+		// Customer drop-offs are not attempts the gateway could have failed
+		total -= stat.CustomerDropped
+		previousTotal -= stat.PreviousCustomerDropped
+		previousRate = stat.PreviousGatewayRate
+	}
+
+	if previousTotal == 0 && stat.HasBaseline {
+		// An absolute floor has no sample of its own
+		stat.PValue = floorPValue(previousRate, stat.Successful, total)
+	} else {
+		stat.PValue = zTestPValue(stat.PreviousSuccessful, previousTotal, stat.Successful, total)
+	}
+	stat.IntervalLow, stat.IntervalHigh = wilsonInterval(stat.Successful, total, o.config.Confidence)
+
+	switch o.config.Significance {
+	case SignificanceNone:
+		return true
+	case SignificanceWilson:
+		return stat.HasBaseline && previousRate > stat.IntervalHigh
+	default:
+		return stat.PValue < 1-o.config.Confidence
+	}
+}
+
+// zTestPValue returns the one-sided p-value of a two-proportion z-test for the
+// current success proportion being lower than the baseline one. Without data
+// on either side nothing can be concluded and 1 is returned.
+func zTestPValue(baselineSuccessful, baselineTotal, currentSuccessful, currentTotal int) float64 {
+	if baselineTotal == 0 || currentTotal == 0 {
+		return 1
+	}
+	n1, n2 := float64(baselineTotal), float64(currentTotal)
+	p1, p2 := float64(baselineSuccessful)/n1, float64(currentSuccessful)/n2
+	pooled := float64(baselineSuccessful+currentSuccessful) / (n1 + n2)
+
+	se := math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2))
+	if se == 0 {
+		// Both windows are all successes or all failures
+		return 1
+	}
+	z := (p1 - p2) / se
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
+
+// floorPValue returns the one-sided p-value of a one-proportion z-test for the
+// current success proportion being lower than an absolute floor, in percent
+func floorPValue(floor float64, currentSuccessful, currentTotal int) float64 {
+	p0 := floor / 100
+	if currentTotal == 0 || p0 <= 0 || p0 >= 1 {
+		return 1
+	}
+	n := float64(currentTotal)
+	p := float64(currentSuccessful) / n
+	z := (p0 - p) / math.Sqrt(p0*(1-p0)/n)
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
+
+// wilsonInterval returns the two-sided Wilson score interval, in percent, of a
+// success proportion at the given confidence level
+func wilsonInterval(successful, total int, confidence float64) (float64, float64) {
+	if total == 0 {
+		return 0, 100
+	}
+	n := float64(total)
+	p := float64(successful) / n
+	z := math.Sqrt2 * math.Erfinv(confidence)
+
+	denominator := 1 + z*z/n
+	center := (p + z*z/(2*n)) / denominator
+	half := z / denominator * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+
+	return math.Max(0, center-half) * 100, math.Min(1, center+half) * 100
+}
@@ -0,0 +1,63 @@
+package observer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// sloDue reports whether the objectives should be evaluated on this tick.
+// Budgets span weeks of rollup rows, so they run on their own interval.
+func (o *Observer) sloDue(now time.Time) bool {
+	if o.config.SLO == nil {
+		return false
+	}
+	if now.Sub(o.lastSLOCheck) < o.config.SLO.Interval() {
+		return false
+	}
+	o.lastSLOCheck = now
+	return true
+}
+
+// checkSLOs evaluates the objectives and alerts on every value burning its
+// error budget too fast over both windows of a burn window
+func (o *Observer) checkSLOs(now time.Time) {
+	report, err := o.config.SLO.Evaluate(now)
+	if err != nil {
+		fmt.Printf("Error evaluating SLOs: %v\n", err)
+		return
+	}
+
+	for _, status := range report.Statuses {
+		for _, burn := range status.Burns {
+			burn := burn
+			fingerprint := models.SLOFingerprint(burn.Objective, burn.Window, status.Dimension, status.Attributes)
+			if !burn.Burning {
+				o.resolve(fingerprint, now)
+				continue
+			}
+
+			fmt.Printf("%s burn of objective %s for %s %s: %.1fx over %s, %.1fx over %s\n", burn.Window, burn.Objective,
+				status.Dimension, status.Attributes, burn.LongBurnRate, burn.LongWindow, burn.ShortBurnRate, burn.ShortWindow)
+			o.fire(status.DimensionOf(), &models.Alert{
+				Type:         models.AlertTypeSLOBurn,
+				Fingerprint:  fingerprint,
+				Dimension:    status.Dimension,
+				Value:        status.Value,
+				Attributes:   status.Attributes,
+				CurrentRate:  burn.SuccessRate,
+				PreviousRate: burn.Target,
+				Baseline:     "slo target",
+				HasBaseline:  true,
+				CurrentTotal: burn.LongTotal,
+				Threshold:    burn.Factor,
+				SLO:          &burn,
+				Timestamp:    now,
+				Gateway:      status.Attributes.Get("gateway"),
+				Method:       status.Attributes.Get("method"),
+				MerchantID:   status.Attributes.Get("merchant_id"),
+			})
+		}
+	}
+}
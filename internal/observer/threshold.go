@@ -0,0 +1,162 @@
+package observer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// ThresholdBasis selects what a success-rate drop is measured by
+type ThresholdBasis string
+
+const (
+	// ThresholdCount compares the drop of the count-based success rate with
+	// the threshold
+	ThresholdCount ThresholdBasis = "count"
+	// ThresholdWeighted compares the drop of the amount-weighted success rate
+	// with the threshold
+	ThresholdWeighted ThresholdBasis = "weighted"
+	// ThresholdGateway compares the drop of the gateway-attributable success
+	// rate, which leaves out customer drop-offs, with the threshold
+	ThresholdGateway ThresholdBasis = "gateway"
+	// ThresholdLostGMV alerts when the estimated lost GMV of the window
+	// reaches the lost GMV threshold
+	ThresholdLostGMV ThresholdBasis = "lost_gmv"
+)
+
+// ParseThresholdBasis validates a threshold basis from config. An empty name
+// selects the count-based rate.
+func ParseThresholdBasis(name string) (ThresholdBasis, error) {
+	switch basis := ThresholdBasis(name); basis {
+	case "":
+		return ThresholdCount, nil
+	case ThresholdCount, ThresholdWeighted, ThresholdGateway, ThresholdLostGMV:
+		return basis, nil
+	default:
+		return "", fmt.Errorf("unknown threshold basis: %s", name)
+	}
+}
+
+// defaultThresholdRule names the global thresholds on alerts
+const defaultThresholdRule = "default"
+
+// ThresholdRule overrides the global thresholds for the dimension values it
+// matches. Nil thresholds keep the global value.
+type ThresholdRule struct {
+	Name            string
+	Dimension       string            // empty matches any dimension
+	Match           map[string]string // attribute name to glob pattern
+	Threshold       *float64
+	MinTransactions *int
+	LostGMV         *float64
+}
+
+// Validate checks that every pattern of the rule is a valid glob
+func (r *ThresholdRule) Validate() error {
+	for name, pattern := range r.Match {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("threshold rule %s: invalid pattern %q for %s: %v", r.Name, pattern, name, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to a value of the dimension. Every
+// attribute the rule matches on must be one the dimension groups by.
+func (r *ThresholdRule) matches(dimension string, attrs models.Attributes) bool {
+	if r.Dimension != "" && r.Dimension != dimension {
+		return false
+	}
+	names := make([]string, 0, len(r.Match))
+	for name := range r.Match {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var value string
+		var found bool
+		for _, attr := range attrs {
+			if attr.Name == name {
+				value, found = attr.Value, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+		if ok, _ := path.Match(r.Match[name], value); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// thresholds are the limits that apply to one dimension value
+type thresholds struct {
+	Rule            string
+	Drop            float64
+	MinTransactions int
+	LostGMV         float64
+}
+
+// thresholdsFor returns the thresholds of the first rule matching the value,
+// with the global thresholds filling in whatever the rule leaves unset
+func (o *Observer) thresholdsFor(dimension string, attrs models.Attributes) thresholds {
+	limits := thresholds{
+		Rule:            defaultThresholdRule,
+		Drop:            o.config.Threshold,
+		MinTransactions: o.config.MinTransactions,
+		LostGMV:         o.config.LostGMVThreshold,
+	}
+	for i := range o.config.ThresholdRules {
+		rule := &o.config.ThresholdRules[i]
+		if !rule.matches(dimension, attrs) {
+			continue
+		}
+		limits.Rule = rule.Name
+		if rule.Threshold != nil {
+			limits.Drop = *rule.Threshold
+		}
+		if rule.MinTransactions != nil {
+			limits.MinTransactions = *rule.MinTransactions
+		}
+		if rule.LostGMV != nil {
+			limits.LostGMV = *rule.LostGMV
+		}
+		break
+	}
+	return limits
+}
+
+// limit returns the threshold compared under the basis
+func (t thresholds) limit(basis ThresholdBasis) float64 {
+	if basis == ThresholdLostGMV {
+		return t.LostGMV
+	}
+	return t.Drop
+}
+
+// breached reports whether a stat crosses its threshold
+func (o *Observer) breached(stat *models.PaymentStats, limits thresholds) bool {
+	switch o.config.ThresholdBasis {
+	case ThresholdWeighted:
+		return stat.WeightedDropPercentage > limits.Drop
+	case ThresholdGateway:
+		return stat.GatewayDropPercentage > limits.Drop
+	case ThresholdLostGMV:
+		return stat.LostGMV > 0 && stat.LostGMV >= limits.LostGMV
+	default:
+		return stat.DropPercentage > limits.Drop
+	}
+}
+
+// requiresSignificance reports whether a breach must also pass the
+// significance test. The tests are on payment counts, so they only gate the
+// count-based thresholds; amount-based thresholds rely on the minimum number
+// of transactions instead.
+func (o *Observer) requiresSignificance() bool {
+	return o.config.ThresholdBasis == ThresholdCount || o.config.ThresholdBasis == ThresholdGateway
+}
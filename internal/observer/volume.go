@@ -0,0 +1,98 @@
+package observer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// checkVolume judges the volume of every value of a dimension, including
+// values that had no payments in the current window. A value that had steady
+// volume and has none now is silent; one whose volume fell too far has a
+// volume drop.
+func (o *Observer) checkVolume(dim *dimension.Dimension, values []*valueStats, now time.Time) {
+	if !o.config.VolumeDetection {
+		return
+	}
+
+	for _, value := range values {
+		verdict := o.detector.Volume(dim, value, now)
+		if verdict.Skip {
+			continue
+		}
+		expected := verdict.Expected
+		current := value.Current.Total
+		volumeDrop := (expected - float64(current)) / expected * 100
+
+		alertType := models.AlertTypeVolumeDrop
+		if verdict.Silent {
+			alertType = models.AlertTypeSilent
+		}
+		fingerprint := models.AlertFingerprint(alertType, dim.Name, value.Attributes)
+
+		if !verdict.Anomalous {
+			o.resolve(fingerprint, now)
+			continue
+		}
+
+		fmt.Printf("%s alert for dimension %s %s: %d payments, %.1f expected\n", alertType, dim.Name, value.Attributes, current, expected)
+		alert := &models.Alert{
+			Type:          alertType,
+			Fingerprint:   fingerprint,
+			Dimension:     dim.Name,
+			Value:         value.Attributes.Label(),
+			Attributes:    value.Attributes,
+			CurrentRate:   value.Current.SuccessRate,
+			Baseline:      o.baselineLabel(),
+			HasBaseline:   true,
+			PValue:        verdict.PValue,
+			CurrentTotal:  int(current),
+			ExpectedTotal: expected,
+			VolumeDrop:    volumeDrop,
+			Forecast:      verdict.Forecast,
+			Timestamp:     now,
+			Gateway:       value.Attributes.Get("gateway"),
+			Method:        value.Attributes.Get("method"),
+			MerchantID:    value.Attributes.Get("merchant_id"),
+		}
+		o.fire(dim, alert)
+	}
+}
+
+// medianTotal returns the median payment count of the baseline windows, the
+// lower middle one for an even number of windows
+func medianTotal(windows []windowStats) (int64, bool) {
+	if len(windows) == 0 {
+		return 0, false
+	}
+	totals := make([]int64, len(windows))
+	for i, window := range windows {
+		totals[i] = window.Total
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i] < totals[j] })
+	return totals[(len(totals)-1)/2], true
+}
+
+// poissonPValue returns the probability of observing at most observed
+// payments when expected are predicted, treating arrivals as Poisson. Large
+// volumes use the normal approximation.
+func poissonPValue(observed int64, expected float64) float64 {
+	if expected <= 0 {
+		return 1
+	}
+	if expected > 1000 {
+		z := (expected - float64(observed) - 0.5) / math.Sqrt(expected)
+		return 0.5 * math.Erfc(z/math.Sqrt2)
+	}
+	// Sum the terms in log space so that large volumes do not overflow
+	var p float64
+	for k := int64(0); k <= observed; k++ {
+		lgamma, _ := math.Lgamma(float64(k) + 1)
+		p += math.Exp(float64(k)*math.Log(expected) - expected - lgamma)
+	}
+	return math.Min(1, p)
+}
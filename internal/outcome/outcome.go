@@ -0,0 +1,125 @@
+package outcome
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Outcome is what a payment counts as in success rates
+type Outcome string
+
+const (
+	// Success payments count towards both success rates
+	Success Outcome = "success"
+	// GatewayFailure payments failed for reasons the gateway is answerable for
+	GatewayFailure Outcome = "gateway_failure"
+	// CustomerDropOff payments were abandoned or declined by the customer.
+	// They lower the raw success rate but not the gateway-attributable one.
+	CustomerDropOff Outcome = "customer_drop_off"
+	// Excluded payments count towards neither rate, e.g. ones still pending
+	Excluded Outcome = "excluded"
+)
+
+// Parse validates an outcome name from config
+func Parse(name string) (Outcome, error) {
+	switch outcome := Outcome(name); outcome {
+	case Success, GatewayFailure, CustomerDropOff, Excluded:
+		return outcome, nil
+	default:
+		return "", fmt.Errorf("unknown payment outcome: %s", name)
+	}
+}
+
+// Rule maps the payments matching all of its non-empty conditions to an
+// outcome
+type Rule struct {
+	Outcome  Outcome
+	Statuses []string // payments.status values
+	Codes    []string // error codes, "error"->>'code'
+	Reasons  []string // error reasons, "error"->>'reason'
+}
+
+// Taxonomy classifies payments by the first rule they match, and by the
+// fallback outcome when none does
+type Taxonomy struct {
+	rules    []Rule
+	fallback Outcome
+	expr     string
+}
+
+// Default counts captured payments as successes and everything else as a
+// gateway failure
+func Default() *Taxonomy {
+	taxonomy, _ := New([]Rule{{Outcome: Success, Statuses: []string{"STATUS_CAPTURED"}}}, GatewayFailure)
+	return taxonomy
+}
+
+// New builds a taxonomy. An empty fallback makes unmatched payments gateway
+// failures.
+func New(rules []Rule, fallback Outcome) (*Taxonomy, error) {
+	if fallback == "" {
+		fallback = GatewayFailure
+	}
+	if _, err := Parse(string(fallback)); err != nil {
+		return nil, err
+	}
+
+	whens := make([]string, 0, len(rules))
+	for i, rule := range rules {
+		if _, err := Parse(string(rule.Outcome)); err != nil {
+			return nil, fmt.Errorf("outcome rule %d: %v", i, err)
+		}
+		var conditions []string
+		if len(rule.Statuses) > 0 {
+			conditions = append(conditions, "status IN ("+quoteAll(rule.Statuses)+")")
+		}
+		if len(rule.Codes) > 0 {
+			conditions = append(conditions, `"error"->>'code' IN (`+quoteAll(rule.Codes)+")")
+		}
+		if len(rule.Reasons) > 0 {
+			conditions = append(conditions, `"error"->>'reason' IN (`+quoteAll(rule.Reasons)+")")
+		}
+		if len(conditions) == 0 {
+			return nil, fmt.Errorf("outcome rule %d (%s) has no statuses, codes or reasons", i, rule.Outcome)
+		}
+		whens = append(whens, fmt.Sprintf("WHEN %s THEN %s", strings.Join(conditions, " AND "), quote(string(rule.Outcome))))
+	}
+
+	expr := quote(string(fallback))
+	if len(whens) > 0 {
+		expr = fmt.Sprintf("CASE %s ELSE %s END", strings.Join(whens, " "), expr)
+	}
+	return &Taxonomy{rules: rules, fallback: fallback, expr: expr}, nil
+}
+
+// Expr returns the SQL expression classifying a row of payments
+func (t *Taxonomy) Expr() string {
+	return t.expr
+}
+
+// Is returns the SQL condition selecting payments with one of the outcomes
+func (t *Taxonomy) Is(outcomes ...Outcome) string {
+	names := make([]string, len(outcomes))
+	for i, outcome := range outcomes {
+		names[i] = string(outcome)
+	}
+	return fmt.Sprintf("(%s) IN (%s)", t.expr, quoteAll(names))
+}
+
+// Counted returns the SQL condition selecting the payments that count towards
+// the raw success rate
+func (t *Taxonomy) Counted() string {
+	return t.Is(Success, GatewayFailure, CustomerDropOff)
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quote(value)
+	}
+	return strings.Join(quoted, ", ")
+}
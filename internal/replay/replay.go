@@ -0,0 +1,167 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// Incident is a known outage the replayed alerts are checked against. An
+// empty Dimension matches any dimension, and Match maps attribute names to
+// glob patterns that must all match.
+type Incident struct {
+	Name      string            `json:"name"`
+	Start     time.Time         `json:"start"`
+	Dimension string            `json:"dimension,omitempty"`
+	Match     map[string]string `json:"match,omitempty"`
+}
+
+// LoadIncidents reads a JSON array of incidents
+func LoadIncidents(file string) ([]Incident, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var incidents []Incident
+	if err := json.Unmarshal(data, &incidents); err != nil {
+		return nil, fmt.Errorf("error parsing incidents %s: %v", file, err)
+	}
+	for _, incident := range incidents {
+		if incident.Start.IsZero() {
+			return nil, fmt.Errorf("incident %q has no start", incident.Name)
+		}
+		for name, pattern := range incident.Match {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("incident %q: invalid pattern %q for %s: %v", incident.Name, pattern, name, err)
+			}
+		}
+	}
+	return incidents, nil
+}
+
+// Report is the outcome of a replay: the alerts the configuration would have
+// raised and how long each known incident took to detect
+type Report struct {
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Step      string            `json:"step"`
+	Alerts    []*models.Alert   `json:"alerts"`
+	Incidents []IncidentOutcome `json:"incidents"`
+	Summary   Summary           `json:"summary"`
+}
+
+// IncidentOutcome is the first alert that detected a known incident, if any
+type IncidentOutcome struct {
+	Incident
+	Detected     bool      `json:"detected"`
+	AlertID      string    `json:"alert_id,omitempty"`
+	DetectedAt   time.Time `json:"detected_at,omitempty"`
+	DelaySeconds float64   `json:"delay_seconds,omitempty"`
+}
+
+type Summary struct {
+	Alerts           int     `json:"alerts"`
+	Incidents        int     `json:"incidents"`
+	Detected         int     `json:"detected"`
+	Missed           int     `json:"missed"`
+	UnmatchedAlerts  int     `json:"unmatched_alerts"` // alerts not matching any incident
+	MeanDelaySeconds float64 `json:"mean_delay_seconds"`
+	MaxDelaySeconds  float64 `json:"max_delay_seconds"`
+}
+
+// NewReport matches the notifications of a replay against the known
+// incidents. Only notifications that opened an incident count as alerts; an
+// incident is detected by the first alert at or after its start that matches
+// its dimension and attributes.
+func NewReport(from, to time.Time, step time.Duration, notifications []*models.Alert, incidents []Incident) *Report {
+	report := &Report{
+		From:      from,
+		To:        to,
+		Step:      step.String(),
+		Alerts:    []*models.Alert{},
+		Incidents: []IncidentOutcome{},
+	}
+	seen := make(map[string]bool)
+	for _, alert := range notifications {
+		if alert.State == models.AlertStateResolved || seen[alert.ID] {
+			continue
+		}
+		seen[alert.ID] = true
+		report.Alerts = append(report.Alerts, alert)
+	}
+	sort.SliceStable(report.Alerts, func(i, j int) bool {
+		return report.Alerts[i].StartedAt.Before(report.Alerts[j].StartedAt)
+	})
+
+	matched := make(map[string]bool)
+	var totalDelay float64
+	for _, incident := range incidents {
+		outcome := IncidentOutcome{Incident: incident}
+		for _, alert := range report.Alerts {
+			if alert.StartedAt.Before(incident.Start) || !incident.matches(alert) {
+				continue
+			}
+			matched[alert.ID] = true
+			if !outcome.Detected {
+				outcome.Detected = true
+				outcome.AlertID = alert.ID
+				outcome.DetectedAt = alert.StartedAt
+				outcome.DelaySeconds = alert.StartedAt.Sub(incident.Start).Seconds()
+			}
+		}
+
+		if outcome.Detected {
+			report.Summary.Detected++
+			totalDelay += outcome.DelaySeconds
+			if outcome.DelaySeconds > report.Summary.MaxDelaySeconds {
+				report.Summary.MaxDelaySeconds = outcome.DelaySeconds
+			}
+		} else {
+			report.Summary.Missed++
+		}
+		report.Incidents = append(report.Incidents, outcome)
+	}
+
+	report.Summary.Alerts = len(report.Alerts)
+	report.Summary.Incidents = len(incidents)
+	report.Summary.UnmatchedAlerts = len(report.Alerts) - len(matched)
+	if report.Summary.Detected > 0 {
+		report.Summary.MeanDelaySeconds = totalDelay / float64(report.Summary.Detected)
+	}
+	return report
+}
+
+// Write encodes the report as indented JSON to file, or to stdout when file
+// is empty
+func (r *Report) Write(file string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+func (i *Incident) matches(alert *models.Alert) bool {
+	if i.Dimension != "" && i.Dimension != alert.Dimension {
+		return false
+	}
+	for name, pattern := range i.Match {
+		value := alert.Attributes.Get(name)
+		if value == "" {
+			return false
+		}
+		if ok, _ := path.Match(pattern, value); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,303 @@
+package rollup
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/internal/outcome"
+	"github.com/yourusername/payment-monitor/pkg/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Table is the rollup table the observer reads window stats from
+const Table = "payment_stats_minutely"
+
+// bucketSize is the rollup granularity
+const bucketSize = 60
+
+// layoutVersion is part of every layout, and is bumped when rows gain counts
+// so that rows written without them are not read as zeros
+const layoutVersion = 2
+
+// Funnel stage conditions. Authorization implies the payment passed any
+// authentication step, so payments of methods without one count as
+// authenticated once authorized. Late authorizations count as authorized.
+const (
+	authorizedCondition    = "(authorized_at > 0 OR late_authorized = 1 OR captured_at > 0)"
+	authenticatedCondition = "(authenticated_at > 0 OR " + authorizedCondition + ")"
+	capturedCondition      = "captured_at > 0"
+)
+
+// backfillChunk bounds the range rolled up in one transaction
+const backfillChunk = time.Hour
+
+// staleAfter is how long the watermark of a layout other than the current one
+// has to go without updates before Prune deletes the layout
+const staleAfter = 24 * time.Hour
+
+// Rollup maintains per-minute aggregates of payments by the attributes of all
+// enabled dimensions, so that windows are compared without rescanning raw
+// payments on every tick
+type Rollup struct {
+	db     *gorm.DB
+	config *Config
+	fields []dimension.Field
+	layout string
+}
+
+type Config struct {
+	// Lateness is how far before the watermark each update recomputes, to pick
+	// up payments that were written after their created_at minute was rolled up
+	Lateness time.Duration
+	// Outcomes classifies payments into successes, failures and exclusions
+	Outcomes *outcome.Taxonomy
+}
+
+// New creates a rollup over the distinct fields of the given dimensions
+func New(db *gorm.DB, config *Config, dimensions []*dimension.Dimension) *Rollup {
+	if config.Lateness == 0 {
+		config.Lateness = 2 * time.Minute
+	}
+	if config.Outcomes == nil {
+		config.Outcomes = outcome.Default()
+	}
+
+	var fields []dimension.Field
+	seen := make(map[string]bool)
+	for _, dim := range dimensions {
+		for _, field := range dim.Fields {
+			if !seen[field.Name] {
+				seen[field.Name] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+
+	return &Rollup{
+		db:     db,
+		config: config,
+		fields: fields,
+		layout: layoutOf(fields, config.Outcomes),
+	}
+}
+
+// Layout identifies the attribute set rows are rolled up by
+func (r *Rollup) Layout() string {
+	return r.layout
+}
+
+// Replay returns a rollup over the same fields and outcomes that keeps its
+// rows apart from the live ones, so that a replay neither rewrites the
+// buckets a live observer reads nor loses its own to the live retention
+func (r *Rollup) Replay() *Rollup {
+	replay := *r
+	replay.layout = r.layout + "-replay"
+	return &replay
+}
+
+// AttributeExpr returns the SQL expression reading a dimension field from a
+// rollup row
+func AttributeExpr(field dimension.Field) string {
+	return fmt.Sprintf("attributes->>'%s'", field.Name)
+}
+
+// Update rolls up payments from the watermark, less the allowed lateness, up
+// to and including the minute of now, then advances the watermark. When the
+// layout has no watermark yet the last lookback of history is rolled up.
+func (r *Rollup) Update(now time.Time, lookback time.Duration) error {
+	watermark, ok, err := r.watermark()
+	if err != nil {
+		return err
+	}
+
+	var from time.Time
+	if ok {
+		from = time.Unix(watermark, 0).Add(-r.config.Lateness)
+	} else {
+		from = now.Add(-lookback)
+		log.Printf("Rollup layout %s has no watermark, rolling up from %s", r.layout, from.Format(time.RFC3339))
+	}
+	to := floor(now).Add(bucketSize * time.Second)
+
+	if err := r.rollupRange(from, to); err != nil {
+		return err
+	}
+	return r.setWatermark(floor(now).Unix())
+}
+
+// Backfill rolls up payments created in [from, to). When the layout has no
+// watermark yet it is set to to, so that incremental updates continue from
+// the end of the backfill.
+func (r *Rollup) Backfill(from, to time.Time) error {
+	log.Printf("Backfilling rollup %s from %s to %s", r.layout, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	// Keep Prune from taking the layout for stale while it is rewritten
+	if err := r.db.Model(&models.RollupWatermark{}).Where("layout = ?", r.layout).
+		Update("updated_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("error touching rollup watermark: %v", err)
+	}
+	if err := r.rollupRange(from, to); err != nil {
+		return err
+	}
+
+	if _, ok, err := r.watermark(); err != nil || ok {
+		return err
+	}
+	return r.setWatermark(floor(to).Unix())
+}
+
+// Prune deletes the rows of the layout older than retention, and every row
+// and watermark of other layouts whose watermark was not updated for a day:
+// those of a previous dimension or outcome config, or of finished replays.
+// Layouts still being backfilled for the first time have no watermark yet
+// and are kept.
+func (r *Rollup) Prune(now time.Time, retention time.Duration) error {
+	cutoff := floor(now.Add(-retention)).Unix()
+	if err := r.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE layout = ? AND bucket < ?", Table), r.layout, cutoff).Error; err != nil {
+		return fmt.Errorf("error pruning rollup: %v", err)
+	}
+
+	var stale []string
+	if err := r.db.Model(&models.RollupWatermark{}).
+		Where("layout <> ? AND updated_at < ?", r.layout, now.Add(-staleAfter)).
+		Pluck("layout", &stale).Error; err != nil {
+		return fmt.Errorf("error reading rollup watermarks: %v", err)
+	}
+	for _, layout := range stale {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE layout = ?", Table), layout).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.RollupWatermark{}, "layout = ?", layout).Error
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting rollup layout %s: %v", layout, err)
+		}
+		log.Printf("Deleted stale rollup layout %s", layout)
+	}
+	return nil
+}
+
+// Buckets returns the range of bucket starts covering [from, to), widened to
+// whole buckets
+func Buckets(from, to time.Time) (int64, int64) {
+	return floor(from).Unix(), ceil(to).Unix()
+}
+
+// rollupRange recomputes the minute buckets covering [from, to), one chunk
+// per transaction
+func (r *Rollup) rollupRange(from, to time.Time) error {
+	for start := floor(from); start.Before(to); start = start.Add(backfillChunk) {
+		end := start.Add(backfillChunk)
+		if end.After(to) {
+			end = to
+		}
+		if err := r.rollupChunk(start, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Rollup) rollupChunk(from, to time.Time) error {
+	fromBucket, toBucket := Buckets(from, to)
+
+	pairs := make([]string, 0, len(r.fields))
+	for _, field := range r.fields {
+		pairs = append(pairs, fmt.Sprintf("'%s', %s", field.Name, field.Expr))
+	}
+	attributes := "jsonb_build_object(" + strings.Join(pairs, ", ") + ")"
+	amount := "COALESCE(NULLIF(base_amount, 0), amount)"
+	counted := fmt.Sprintf("outcome IN ('%s', '%s', '%s')", outcome.Success, outcome.GatewayFailure, outcome.CustomerDropOff)
+	successful := fmt.Sprintf("outcome = '%s'", outcome.Success)
+
+	// Each payment is classified once; excluded payments are only counted in
+	// their own column
+	insert := fmt.Sprintf(`INSERT INTO %s (layout, bucket, attributes, total, successful, customer_dropped, excluded,
+	authenticated, authorized, captured, total_amount, successful_amount)
+SELECT ?, created_at - created_at %% %d, %s,
+	COUNT(*) FILTER (WHERE %s),
+	COUNT(*) FILTER (WHERE %s),
+	COUNT(*) FILTER (WHERE outcome = '%s'),
+	COUNT(*) FILTER (WHERE outcome = '%s'),
+	COUNT(*) FILTER (WHERE %s AND %s),
+	COUNT(*) FILTER (WHERE %s AND %s),
+	COUNT(*) FILTER (WHERE %s AND %s),
+	COALESCE(SUM(%s) FILTER (WHERE %s), 0),
+	COALESCE(SUM(%s) FILTER (WHERE %s), 0)
+FROM (SELECT *, %s AS outcome FROM payments WHERE created_at >= ? AND created_at < ?) payments
+GROUP BY 2, 3`, Table, bucketSize, attributes,
+		counted, successful, outcome.CustomerDropOff, outcome.Excluded,
+		counted, authenticatedCondition, counted, authorizedCondition, counted, capturedCondition,
+		amount, counted, amount, successful,
+		r.config.Outcomes.Expr())
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE layout = ? AND bucket >= ? AND bucket < ?", Table),
+			r.layout, fromBucket, toBucket).Error; err != nil {
+			return fmt.Errorf("error clearing rollup buckets: %v", err)
+		}
+		if err := tx.Exec(insert, r.layout, fromBucket, toBucket).Error; err != nil {
+			return fmt.Errorf("error rolling up payments: %v", err)
+		}
+		return nil
+	})
+}
+
+func (r *Rollup) watermark() (int64, bool, error) {
+	var mark models.RollupWatermark
+	err := r.db.First(&mark, "layout = ?", r.layout).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("error reading rollup watermark: %v", err)
+	}
+	return mark.Watermark, true, nil
+}
+
+func (r *Rollup) setWatermark(watermark int64) error {
+	mark := models.RollupWatermark{Layout: r.layout, Watermark: watermark}
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "layout"}},
+		DoUpdates: clause.AssignmentColumns([]string{"watermark", "updated_at"}),
+	}).Create(&mark).Error
+	if err != nil {
+		return fmt.Errorf("error advancing rollup watermark: %v", err)
+	}
+	return nil
+}
+
+// layoutOf hashes the field names and expressions a rollup groups by, and
+// the outcome classification its counts depend on
+func layoutOf(fields []dimension.Field, outcomes *outcome.Taxonomy) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "v%d;", layoutVersion)
+	for _, field := range fields {
+		fmt.Fprintf(h, "%s=%s;", field.Name, field.Expr)
+	}
+	fmt.Fprintf(h, "outcome=%s;", outcomes.Expr())
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// floor truncates t to the start of its rollup bucket
+func floor(t time.Time) time.Time {
+	return time.Unix(t.Unix()-t.Unix()%bucketSize, 0)
+}
+
+// ceil rounds t up to the start of the next rollup bucket, unless it already
+// is one
+func ceil(t time.Time) time.Time {
+	if t.Unix()%bucketSize == 0 {
+		return time.Unix(t.Unix(), 0)
+	}
+	return floor(t).Add(bucketSize * time.Second)
+}
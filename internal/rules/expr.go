@@ -0,0 +1,383 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Type is the type of an expression or variable
+type Type int
+
+const (
+	TypeNumber Type = iota
+	TypeBool
+	TypeString
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeBool:
+		return "boolean"
+	case TypeString:
+		return "string"
+	default:
+		return "number"
+	}
+}
+
+// Env resolves the variables of an expression while it is evaluated. Each
+// variable is only read with the type it was declared with.
+type Env interface {
+	Number(name string) float64
+	Bool(name string) bool
+	String(name string) string
+}
+
+// Lookup declares the variables an expression may use, returning the type of
+// a variable and whether it exists
+type Lookup func(name string) (Type, bool)
+
+// Expr is a compiled boolean expression, e.g.
+//
+//	success_rate < 85 && total > 200 && drop > 5
+//
+// It supports numbers, 'single' or "double" quoted strings, true and false,
+// variables, arithmetic (+ - * /), comparisons (< <= > >= == !=), logic
+// (&& || !) and parentheses, with the usual precedence. Expressions are type
+// checked when compiled, so evaluating one cannot fail.
+type Expr struct {
+	source string
+	root   node
+}
+
+// Compile parses and type checks a boolean expression
+func Compile(source string, lookup Lookup) (*Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, lookup: lookup}
+	root, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+	}
+	if root.typ() != TypeBool {
+		return nil, fmt.Errorf("expression is a %s, not a condition", root.typ())
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.source
+}
+
+// Eval reports whether the expression holds in env
+func (e *Expr) Eval(env Env) bool {
+	return e.root.eval(env).b
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// operators are matched longest first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")"}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.' || source[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, source[start:i], start})
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(source) && (source[i] == '_' || source[i] == '.' || unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, source[start:i], start})
+		case c == '\'' || c == '"':
+			start := i
+			end := strings.IndexByte(source[i+1:], source[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i += end + 2
+			tokens = append(tokens, token{tokenString, source[start+1 : i-1], start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, token{tokenOperator, op, i})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(source)}), nil
+}
+
+// precedence of the binary operators; higher binds tighter
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6,
+}
+
+// unaryPrecedence binds ! and unary - tighter than any binary operator
+const unaryPrecedence = 7
+
+type parser struct {
+	tokens []token
+	next   int
+	lookup Lookup
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+// expression parses operators binding tighter than minPrecedence, by
+// precedence climbing
+func (p *parser) expression(minPrecedence int) (node, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		prec, ok := precedence[tok.text]
+		if tok.kind != tokenOperator || !ok || prec <= minPrecedence {
+			return left, nil
+		}
+		p.advance()
+		right, err := p.expression(prec)
+		if err != nil {
+			return nil, err
+		}
+		if left, err = newBinary(tok, left, right); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) operand() (node, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokenNumber:
+		n, err := strconv.ParseFloat(strings.ReplaceAll(tok.text, "_", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at offset %d", tok, tok.pos)
+		}
+		return literal{value{num: n}, TypeNumber}, nil
+	case tokenString:
+		return literal{value{str: tok.text}, TypeString}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true", "false":
+			return literal{value{b: tok.text == "true"}, TypeBool}, nil
+		}
+		t, ok := p.lookup(tok.text)
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %s at offset %d", tok, tok.pos)
+		}
+		return variable{tok.text, t}, nil
+	case tokenOperator:
+		switch tok.text {
+		case "(":
+			inner, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			if closing := p.advance(); closing.text != ")" || closing.kind != tokenOperator {
+				return nil, fmt.Errorf("expected \")\" at offset %d, got %s", closing.pos, closing)
+			}
+			return inner, nil
+		case "!", "-":
+			operand, err := p.expression(unaryPrecedence)
+			if err != nil {
+				return nil, err
+			}
+			want := TypeBool
+			if tok.text == "-" {
+				want = TypeNumber
+			}
+			if operand.typ() != want {
+				return nil, fmt.Errorf("%s at offset %d needs a %s, not a %s", tok, tok.pos, want, operand.typ())
+			}
+			return unary{tok.text, operand}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at offset %d", tok, tok.pos)
+}
+
+// newBinary type checks a binary operation
+func newBinary(tok token, left, right node) (node, error) {
+	lt, rt := left.typ(), right.typ()
+	var operands, result Type
+	switch tok.text {
+	case "&&", "||":
+		operands, result = TypeBool, TypeBool
+	case "==", "!=":
+		operands, result = lt, TypeBool
+	case "<", "<=", ">", ">=":
+		operands, result = TypeNumber, TypeBool
+	default:
+		operands, result = TypeNumber, TypeNumber
+	}
+	if lt != operands || rt != operands {
+		return nil, fmt.Errorf("%s at offset %d cannot combine a %s and a %s", tok, tok.pos, lt, rt)
+	}
+	return binary{tok.text, left, right, result}, nil
+}
+
+type value struct {
+	num float64
+	b   bool
+	str string
+}
+
+type node interface {
+	typ() Type
+	eval(env Env) value
+}
+
+type literal struct {
+	v value
+	t Type
+}
+
+func (l literal) typ() Type      { return l.t }
+func (l literal) eval(Env) value { return l.v }
+
+type variable struct {
+	name string
+	t    Type
+}
+
+func (v variable) typ() Type { return v.t }
+
+func (v variable) eval(env Env) value {
+	switch v.t {
+	case TypeBool:
+		return value{b: env.Bool(v.name)}
+	case TypeString:
+		return value{str: env.String(v.name)}
+	default:
+		return value{num: env.Number(v.name)}
+	}
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (u unary) typ() Type {
+	return u.operand.typ()
+}
+
+func (u unary) eval(env Env) value {
+	v := u.operand.eval(env)
+	if u.op == "!" {
+		return value{b: !v.b}
+	}
+	return value{num: -v.num}
+}
+
+type binary struct {
+	op          string
+	left, right node
+	t           Type
+}
+
+func (b binary) typ() Type { return b.t }
+
+// eval follows IEEE 754, except that dividing by zero gives NaN rather than
+// an infinity, so that no comparison but != holds for it: a ratio such as
+// failed / successful is undefined, not large, when nothing succeeded
+func (b binary) eval(env Env) value {
+	l := b.left.eval(env)
+	// && and || short-circuit
+	switch b.op {
+	case "&&":
+		return value{b: l.b && b.right.eval(env).b}
+	case "||":
+		return value{b: l.b || b.right.eval(env).b}
+	}
+
+	r := b.right.eval(env)
+	switch b.op {
+	case "==":
+		return value{b: l == r}
+	case "!=":
+		return value{b: l != r}
+	case "<":
+		return value{b: l.num < r.num}
+	case "<=":
+		return value{b: l.num <= r.num}
+	case ">":
+		return value{b: l.num > r.num}
+	case ">=":
+		return value{b: l.num >= r.num}
+	case "+":
+		return value{num: l.num + r.num}
+	case "-":
+		return value{num: l.num - r.num}
+	case "*":
+		return value{num: l.num * r.num}
+	default:
+		if r.num == 0 {
+			return value{num: math.NaN()}
+		}
+		return value{num: l.num / r.num}
+	}
+}
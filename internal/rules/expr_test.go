@@ -0,0 +1,144 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+// testEnv serves variables from maps and records which ones were read
+type testEnv struct {
+	numbers  map[string]float64
+	booleans map[string]bool
+	strings  map[string]string
+	read     map[string]bool
+}
+
+func newTestEnv() *testEnv {
+	return &testEnv{
+		numbers:  map[string]float64{"total": 250, "success_rate": 80},
+		booleans: map[string]bool{"has_baseline": true, "unreached": true},
+		strings:  map[string]string{"gateway": "hdfc"},
+		read:     make(map[string]bool),
+	}
+}
+
+func (e *testEnv) Number(name string) float64 { e.read[name] = true; return e.numbers[name] }
+func (e *testEnv) Bool(name string) bool      { e.read[name] = true; return e.booleans[name] }
+func (e *testEnv) String(name string) string  { e.read[name] = true; return e.strings[name] }
+
+func (e *testEnv) lookup(name string) (Type, bool) {
+	if _, ok := e.numbers[name]; ok {
+		return TypeNumber, true
+	}
+	if _, ok := e.booleans[name]; ok {
+		return TypeBool, true
+	}
+	if _, ok := e.strings[name]; ok {
+		return TypeString, true
+	}
+	return 0, false
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{"multiplication before addition", "1 + 2 * 3 == 7", true},
+		{"parentheses", "(1 + 2) * 3 == 9", true},
+		{"subtraction is left associative", "10 - 4 - 3 == 3", true},
+		{"division is left associative", "8 / 4 / 2 == 1", true},
+		{"unary minus", "-2 * 3 == -6", true},
+		{"not before and", "!false && false", false},
+		{"and before or", "true || false && false", true},
+		{"comparison before equality", "1 < 2 == true", true},
+		{"variables", "success_rate < 85 && total > 200", true},
+		{"string equality", "gateway == 'hdfc' && gateway != \"icici\"", true},
+		{"digit separators", "total >= 1_000", false},
+		{"boolean variable", "!has_baseline", false},
+		{"division by zero is not large", "total / 0 > 1_000_000", false},
+		{"division by zero is not small", "total / 0 <= 0", false},
+		{"division by zero is not equal to itself", "total / 0 == total / 0", false},
+		{"division by zero differs", "total / 0 != 1", true},
+		{"zero over zero", "0 / 0 >= 0 || 0 / 0 < 0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv()
+			expr, err := Compile(tt.expr, env.lookup)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expr, err)
+			}
+			if got := expr.Eval(env); got != tt.want {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalShortCircuits(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+		read bool // whether unreached is read
+	}{
+		{"false && unreached", false, false},
+		{"true || unreached", true, false},
+		{"total < 100 && unreached", false, false},
+		{"total > 100 || unreached", true, false},
+		{"true && unreached", true, true},
+		{"false || unreached", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			env := newTestEnv()
+			expr, err := Compile(tt.expr, env.lookup)
+			if err != nil {
+				t.Fatalf("Compile(%q) failed: %v", tt.expr, err)
+			}
+			if got := expr.Eval(env); got != tt.want {
+				t.Errorf("Eval(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+			if env.read["unreached"] != tt.read {
+				t.Errorf("Eval(%q) read unreached: %v, want %v", tt.expr, env.read["unreached"], tt.read)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"not a condition", "total + 1", "is a number, not a condition"},
+		{"number and boolean", "total && true", `"&&" at offset 6 cannot combine a number and a boolean`},
+		{"string comparison", "gateway < 5", `"<" at offset 8 cannot combine a string and a number`},
+		{"number and string equality", "1 == '1'", `"==" at offset 2 cannot combine a number and a string`},
+		{"negated number", "!total", `"!" at offset 0 needs a boolean, not a number`},
+		{"negative string", "-gateway == 'x'", `"-" at offset 0 needs a number, not a string`},
+		{"unknown variable", "succes_rate < 90", `unknown variable "succes_rate" at offset 0`},
+		{"unknown variable on the right", "total > limit", `unknown variable "limit" at offset 8`},
+		{"malformed number", "total > 1.2.3", `invalid number "1.2.3" at offset 8`},
+		{"lone dot", "total > .", `invalid number "." at offset 8`},
+		{"unterminated string", "gateway == 'hdfc", "unterminated string at offset 11"},
+		{"unexpected character", "total > 1 $", `unexpected character '$' at offset 10`},
+		{"unclosed parenthesis", "(total > 1", `expected ")" at offset 10, got end of expression`},
+		{"trailing parenthesis", "total > 1)", `unexpected ")" at offset 9`},
+		{"missing operand", "total ==", "unexpected end of expression at offset 8"},
+		{"empty", "", "unexpected end of expression at offset 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.expr, newTestEnv().lookup)
+			if err == nil {
+				t.Fatalf("Compile(%q) succeeded, want error %q", tt.expr, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Compile(%q) error = %q, want %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
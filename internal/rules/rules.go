@@ -0,0 +1,146 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/payment-monitor/pkg/models"
+)
+
+// Severity ranks how urgent the alerts of a rule are
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// ParseSeverity validates a severity from config. An empty severity is a
+// warning.
+func ParseSeverity(name string) (Severity, error) {
+	switch severity := Severity(name); severity {
+	case "":
+		return SeverityWarning, nil
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+		return severity, nil
+	default:
+		return "", fmt.Errorf("unknown severity: %s", name)
+	}
+}
+
+// attributePrefix selects a dimension attribute, e.g. attr.gateway. Values
+// of dimensions without the attribute read as "".
+const attributePrefix = "attr."
+
+// numbers, booleans and texts are the variables of rules, read from the
+// stats of a dimension value. Rates and drops are percentages and amounts
+// minor units of the base currency, as in PaymentStats.
+var (
+	numbers = map[string]func(*models.PaymentStats) float64{
+		"total":                     func(s *models.PaymentStats) float64 { return float64(s.Total) },
+		"successful":                func(s *models.PaymentStats) float64 { return float64(s.Successful) },
+		"success_rate":              func(s *models.PaymentStats) float64 { return s.SuccessRate },
+		"previous_total":            func(s *models.PaymentStats) float64 { return float64(s.PreviousTotal) },
+		"previous_successful":       func(s *models.PaymentStats) float64 { return float64(s.PreviousSuccessful) },
+		"previous_rate":             func(s *models.PaymentStats) float64 { return s.PreviousRate },
+		"drop":                      func(s *models.PaymentStats) float64 { return s.DropPercentage },
+		"customer_dropped":          func(s *models.PaymentStats) float64 { return float64(s.CustomerDropped) },
+		"previous_customer_dropped": func(s *models.PaymentStats) float64 { return float64(s.PreviousCustomerDropped) },
+		"gateway_rate":              func(s *models.PaymentStats) float64 { return s.GatewayRate },
+		"previous_gateway_rate":     func(s *models.PaymentStats) float64 { return s.PreviousGatewayRate },
+		"gateway_drop":              func(s *models.PaymentStats) float64 { return s.GatewayDropPercentage },
+		"total_amount":              func(s *models.PaymentStats) float64 { return float64(s.TotalAmount) },
+		"successful_amount":         func(s *models.PaymentStats) float64 { return float64(s.SuccessfulAmount) },
+		"weighted_rate":             func(s *models.PaymentStats) float64 { return s.WeightedRate },
+		"previous_weighted_rate":    func(s *models.PaymentStats) float64 { return s.PreviousWeightedRate },
+		"weighted_drop":             func(s *models.PaymentStats) float64 { return s.WeightedDropPercentage },
+		"lost_gmv":                  func(s *models.PaymentStats) float64 { return s.LostGMV },
+		"p_value":                   func(s *models.PaymentStats) float64 { return s.PValue },
+	}
+	booleans = map[string]func(*models.PaymentStats) bool{
+		"has_baseline": func(s *models.PaymentStats) bool { return s.HasBaseline },
+		"new_value":    func(s *models.PaymentStats) bool { return s.NewValue },
+	}
+	texts = map[string]func(*models.PaymentStats) string{
+		"dimension": func(s *models.PaymentStats) string { return s.Dimension },
+		"value":     func(s *models.PaymentStats) string { return s.Value },
+		"stage":     func(s *models.PaymentStats) string { return s.Stage },
+	}
+)
+
+// lookup declares the variables of rules
+func lookup(name string) (Type, bool) {
+	if _, ok := numbers[name]; ok {
+		return TypeNumber, true
+	}
+	if _, ok := booleans[name]; ok {
+		return TypeBool, true
+	}
+	if _, ok := texts[name]; ok {
+		return TypeString, true
+	}
+	if strings.HasPrefix(name, attributePrefix) && len(name) > len(attributePrefix) {
+		return TypeString, true
+	}
+	return 0, false
+}
+
+// statsEnv evaluates rules against the stats of one dimension value
+type statsEnv struct {
+	stat *models.PaymentStats
+}
+
+func (e statsEnv) Number(name string) float64 { return numbers[name](e.stat) }
+func (e statsEnv) Bool(name string) bool      { return booleans[name](e.stat) }
+
+func (e statsEnv) String(name string) string {
+	if text, ok := texts[name]; ok {
+		return text(e.stat)
+	}
+	return e.stat.Attributes.Get(strings.TrimPrefix(name, attributePrefix))
+}
+
+// Rule is a named alert condition on the stats of dimension values
+type Rule struct {
+	Name     string
+	Severity Severity
+	Labels   map[string]string
+	expr     *Expr
+}
+
+// New compiles a rule, checking its expression and severity
+func New(name, expr, severity string, labels map[string]string) (*Rule, error) {
+	if name == "" {
+		return nil, fmt.Errorf("alert rule name is required")
+	}
+	compiled, err := Compile(expr, lookup)
+	if err != nil {
+		return nil, fmt.Errorf("alert rule %s: %v", name, err)
+	}
+	parsed, err := ParseSeverity(severity)
+	if err != nil {
+		return nil, fmt.Errorf("alert rule %s: %v", name, err)
+	}
+	return &Rule{Name: name, Severity: parsed, Labels: labels, expr: compiled}, nil
+}
+
+// Expr returns the source of the rule's expression
+func (r *Rule) Expr() string {
+	return r.expr.String()
+}
+
+// Match reports whether the rule fires for the stats of a dimension value
+func (r *Rule) Match(stat *models.PaymentStats) bool {
+	return r.expr.Eval(statsEnv{stat})
+}
+
+// Record describes the rule for the alerts it fires
+func (r *Rule) Record() *models.RuleMatch {
+	return &models.RuleMatch{
+		Name:       r.Name,
+		Expression: r.Expr(),
+		Severity:   string(r.Severity),
+		Labels:     r.Labels,
+	}
+}
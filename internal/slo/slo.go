@@ -0,0 +1,347 @@
+package slo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/payment-monitor/internal/dimension"
+	"github.com/yourusername/payment-monitor/internal/rollup"
+	"github.com/yourusername/payment-monitor/pkg/models"
+	"gorm.io/gorm"
+)
+
+// Basis selects the payments an objective counts
+type Basis string
+
+const (
+	// BasisCount counts every payment that counts towards the raw success rate
+	BasisCount Basis = "count"
+	// BasisGateway leaves out customer drop-offs, like the gateway-attributable
+	// success rate
+	BasisGateway Basis = "gateway"
+)
+
+// Objective is a success-rate objective for every value of a dimension, or
+// for those matching Match, e.g. 97% of payments per gateway over 30 days
+type Objective struct {
+	Name      string
+	Dimension *dimension.Dimension
+	Match     map[string]string // attribute name to glob pattern
+	Target    float64           // percent of payments that succeed
+	Window    time.Duration
+	Basis     Basis
+}
+
+// Validate checks the target, window and patterns of the objective
+func (o *Objective) Validate() error {
+	if o.Name == "" {
+		return fmt.Errorf("objective name is required")
+	}
+	if o.Target <= 0 || o.Target >= 100 {
+		return fmt.Errorf("objective %s: target must be between 0 and 100", o.Name)
+	}
+	if o.Window <= 0 {
+		return fmt.Errorf("objective %s: window must be positive", o.Name)
+	}
+	switch o.Basis {
+	case BasisCount, BasisGateway:
+	default:
+		return fmt.Errorf("objective %s: unknown basis %q", o.Name, o.Basis)
+	}
+	for name, pattern := range o.Match {
+		if !o.Dimension.HasField(name) {
+			return fmt.Errorf("objective %s: dimension %s has no attribute %s", o.Name, o.Dimension.Name, name)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("objective %s: invalid pattern %q for %s: %v", o.Name, pattern, name, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the objective applies to a value of its dimension
+func (o *Objective) matches(attrs models.Attributes) bool {
+	for name, pattern := range o.Match {
+		if ok, _ := path.Match(pattern, attrs.Get(name)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// BurnWindow alerts when a value burns its error budget Factor times faster
+// than the objective allows, over both the Long and the Short window. The
+// short window lets the alert resolve soon after the burn stops.
+type BurnWindow struct {
+	Name   string
+	Long   time.Duration
+	Short  time.Duration
+	Factor float64
+}
+
+// DefaultBurnWindows are the usual pair for a 30 day objective: the fast
+// burn spends 2% of the budget in an hour, the slow burn 5% in six hours
+var DefaultBurnWindows = []BurnWindow{
+	{Name: "fast", Long: time.Hour, Short: 5 * time.Minute, Factor: 14.4},
+	{Name: "slow", Long: 6 * time.Hour, Short: 30 * time.Minute, Factor: 6},
+}
+
+type Config struct {
+	Objectives      []*Objective
+	BurnWindows     []BurnWindow
+	MinTransactions int           // payments the short window of a burn needs to alert
+	Interval        time.Duration // how often objectives are evaluated; reports are reused meanwhile
+}
+
+// Status is the error budget of an objective for one dimension value
+type Status struct {
+	Objective       string            `json:"objective"`
+	Dimension       string            `json:"dimension"`
+	Value           string            `json:"value"`
+	Attributes      models.Attributes `json:"attributes"`
+	Target          float64           `json:"target"`
+	Window          string            `json:"window"`
+	Total           int               `json:"total"`
+	Failed          int               `json:"failed"`
+	SuccessRate     float64           `json:"success_rate"`
+	BudgetAllowed   float64           `json:"budget_allowed"`   // failures the target allows for the window's volume
+	BudgetConsumed  float64           `json:"budget_consumed"`  // percent of the budget spent
+	BudgetRemaining float64           `json:"budget_remaining"` // percent of the budget left, negative once exhausted
+	Burns           []models.SLOBurn  `json:"burns"`
+
+	dimension *dimension.Dimension
+}
+
+// DimensionOf returns the dimension the status is of
+func (s *Status) DimensionOf() *dimension.Dimension {
+	return s.dimension
+}
+
+// Report is the status of every objective at a point in time
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Statuses    []*Status `json:"statuses"`
+}
+
+// Tracker computes error budgets and burn rates from the minutely rollup,
+// which has to group by the fields of every objective's dimension
+type Tracker struct {
+	db     *gorm.DB
+	config *Config
+	rollup *rollup.Rollup
+
+	refresh sync.Mutex // held while a stale report is evaluated again
+	mu      sync.Mutex
+	report  *Report
+}
+
+func NewTracker(db *gorm.DB, config *Config, rollup *rollup.Rollup) *Tracker {
+	if len(config.BurnWindows) == 0 {
+		config.BurnWindows = DefaultBurnWindows
+	}
+	if config.Interval == 0 {
+		config.Interval = 5 * time.Minute
+	}
+	return &Tracker{db: db, config: config, rollup: rollup}
+}
+
+// Interval is how often objectives are evaluated
+func (t *Tracker) Interval() time.Duration {
+	return t.config.Interval
+}
+
+// Lookback is the longest window an evaluation reads from the rollup
+func (t *Tracker) Lookback() time.Duration {
+	var lookback time.Duration
+	for _, objective := range t.config.Objectives {
+		if objective.Window > lookback {
+			lookback = objective.Window
+		}
+	}
+	for _, burn := range t.config.BurnWindows {
+		if burn.Long > lookback {
+			lookback = burn.Long
+		}
+	}
+	return lookback
+}
+
+// Evaluate computes the status of every objective as of now and keeps it as
+// the latest report
+func (t *Tracker) Evaluate(now time.Time) (*Report, error) {
+	report := &Report{GeneratedAt: now}
+	for _, objective := range t.config.Objectives {
+		statuses, err := t.evaluate(objective, now)
+		if err != nil {
+			return nil, fmt.Errorf("objective %s: %v", objective.Name, err)
+		}
+		report.Statuses = append(report.Statuses, statuses...)
+	}
+
+	t.mu.Lock()
+	t.report = report
+	t.mu.Unlock()
+	return report, nil
+}
+
+// Report returns the latest report, evaluating the objectives again when it
+// is older than the interval. Replicas that do not run the observer evaluate
+// at most once per interval, however many requests arrive meanwhile.
+func (t *Tracker) Report(now time.Time) (*Report, error) {
+	t.refresh.Lock()
+	defer t.refresh.Unlock()
+
+	t.mu.Lock()
+	report := t.report
+	t.mu.Unlock()
+	if report != nil && now.Sub(report.GeneratedAt) < t.config.Interval {
+		return report, nil
+	}
+	return t.Evaluate(now)
+}
+
+// evaluate sums the payments and failures of every value of the objective's
+// dimension over the objective window and both windows of every burn window,
+// in a single pass over the rollup. Windows are widened to whole minutes.
+func (t *Tracker) evaluate(objective *Objective, now time.Time) ([]*Status, error) {
+	windows := []time.Duration{objective.Window}
+	for _, burn := range t.config.BurnWindows {
+		windows = append(windows, burn.Long, burn.Short)
+	}
+
+	counted, failed := "total", "total - successful"
+	if objective.Basis == BasisGateway {
+		counted, failed = "total - customer_dropped", "total - customer_dropped - successful"
+	}
+
+	exprs := make([]string, len(objective.Dimension.Fields))
+	for i, field := range objective.Dimension.Fields {
+		exprs[i] = rollup.AttributeExpr(field)
+	}
+	selects := append([]string{}, exprs...)
+	var args []interface{}
+	var earliest int64
+	_, to := rollup.Buckets(now, now)
+	for _, window := range windows {
+		from, _ := rollup.Buckets(now.Add(-window), now)
+		if earliest == 0 || from < earliest {
+			earliest = from
+		}
+		selects = append(selects,
+			fmt.Sprintf("COALESCE(SUM(%s) FILTER (WHERE bucket >= ?), 0)::bigint", counted),
+			fmt.Sprintf("COALESCE(SUM(%s) FILTER (WHERE bucket >= ?), 0)::bigint", failed))
+		args = append(args, from, from)
+	}
+	args = append(args, t.rollup.Layout(), earliest, to)
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE layout = ? AND bucket >= ? AND bucket < ? GROUP BY %s",
+		strings.Join(selects, ", "), rollup.Table, strings.Join(exprs, ", "))
+	rows, err := t.db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []*Status
+	for rows.Next() {
+		values := make([]sql.NullString, len(exprs))
+		counts := make([]int64, 2*len(windows))
+		dest := make([]interface{}, 0, len(values)+len(counts))
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		for i := range counts {
+			dest = append(dest, &counts[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		attrs := make(models.Attributes, len(exprs))
+		for i, field := range objective.Dimension.Fields {
+			attrs[i] = models.Attribute{Name: field.Name, Value: values[i].String}
+		}
+		if !objective.matches(attrs) {
+			continue
+		}
+		statuses = append(statuses, t.status(objective, attrs, counts))
+	}
+	return statuses, rows.Err()
+}
+
+// status computes the budget of a value from its counts: payments and
+// failures over the objective window, then over the long and short window of
+// each burn window in turn
+func (t *Tracker) status(objective *Objective, attrs models.Attributes, counts []int64) *Status {
+	allowedRate := 1 - objective.Target/100
+	total, failed := int(counts[0]), int(counts[1])
+	status := &Status{
+		Objective:       objective.Name,
+		Dimension:       objective.Dimension.Name,
+		Value:           attrs.Label(),
+		Attributes:      attrs,
+		Target:          objective.Target,
+		Window:          objective.Window.String(),
+		Total:           total,
+		Failed:          failed,
+		BudgetAllowed:   allowedRate * float64(total),
+		BudgetRemaining: 100,
+		dimension:       objective.Dimension,
+	}
+	if total > 0 {
+		status.SuccessRate = float64(total-failed) / float64(total) * 100
+		status.BudgetConsumed = float64(failed) / status.BudgetAllowed * 100
+		status.BudgetRemaining = 100 - status.BudgetConsumed
+	}
+
+	burnRate := func(total, failed int64) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(failed) / float64(total) / allowedRate
+	}
+	for i, window := range t.config.BurnWindows {
+		long, short := counts[2+4*i:4+4*i], counts[4+4*i:6+4*i]
+		burn := models.SLOBurn{
+			Objective:       objective.Name,
+			Target:          objective.Target,
+			Window:          window.Name,
+			LongWindow:      window.Long.String(),
+			ShortWindow:     window.Short.String(),
+			Factor:          window.Factor,
+			LongBurnRate:    burnRate(long[0], long[1]),
+			ShortBurnRate:   burnRate(short[0], short[1]),
+			LongTotal:       int(long[0]),
+			ShortTotal:      int(short[0]),
+			BudgetRemaining: status.BudgetRemaining,
+		}
+		if long[0] > 0 {
+			burn.SuccessRate = float64(long[0]-long[1]) / float64(long[0]) * 100
+		}
+		burn.Burning = burn.ShortTotal > 0 && burn.ShortTotal >= t.config.MinTransactions &&
+			burn.LongBurnRate >= window.Factor && burn.ShortBurnRate >= window.Factor
+		status.Burns = append(status.Burns, burn)
+	}
+	return status
+}
+
+func (t *Tracker) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/slo", t.getReport)
+}
+
+func (t *Tracker) getReport(w http.ResponseWriter, r *http.Request) {
+	report, err := t.Report(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
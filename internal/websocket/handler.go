@@ -6,7 +6,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-redis/redis"
 	"github.com/gorilla/websocket"
+	"github.com/yourusername/payment-monitor/pkg/models"
 )
 
 type Client struct {
@@ -15,26 +17,62 @@ type Client struct {
 }
 
 type MetricsMessage struct {
-	Type        string    `json:"type"`
-	Dimension   string    `json:"dimension"`
-	Value       string    `json:"value"`
-	SuccessRate float64   `json:"success_rate"`
-	Timestamp   time.Time `json:"timestamp"`
+	Type        string            `json:"type"`
+	Dimension   string            `json:"dimension"`
+	Value       string            `json:"value"`
+	Attributes  models.Attributes `json:"attributes"`
+	SuccessRate float64           `json:"success_rate"`
+	HasBaseline bool              `json:"has_baseline"`
+	NewValue    bool              `json:"new_value"` // compared against a fallback, not the baseline windows
+	Timestamp   time.Time         `json:"timestamp"`
 }
 
 type AlertMessage struct {
-	Type            string    `json:"type"`
-	ID              string    `json:"id"`
-	Dimension       string    `json:"dimension"`
-	Value           string    `json:"value"`
-	CurrentRate     float64   `json:"current_rate"`
-	PreviousRate    float64   `json:"previous_rate"`
-	DropPercentage  float64   `json:"drop_percentage"`
-	Timestamp       time.Time `json:"timestamp"`
-	RootCause       string    `json:"root_cause,omitempty"`
-	Confidence      float64   `json:"confidence,omitempty"`
-	Recommendations []string  `json:"recommendations,omitempty"`
-	RelatedChanges  []string  `json:"related_changes,omitempty"`
+	Type                   string                `json:"type"`
+	ID                     string                `json:"id"`
+	AlertType              string                `json:"alert_type"`
+	Fingerprint            string                `json:"fingerprint"`
+	State                  string                `json:"state"`
+	Dimension              string                `json:"dimension"`
+	Value                  string                `json:"value"`
+	Attributes             models.Attributes     `json:"attributes"`
+	CurrentRate            float64               `json:"current_rate"`
+	PreviousRate           float64               `json:"previous_rate"`
+	Baseline               string                `json:"baseline"`
+	HasBaseline            bool                  `json:"has_baseline"`
+	NewValue               bool                  `json:"new_value"`
+	DropPercentage         float64               `json:"drop_percentage"`
+	CustomerDropped        int                   `json:"customer_dropped"`
+	GatewayRate            float64               `json:"gateway_rate"`
+	PreviousGatewayRate    float64               `json:"previous_gateway_rate"`
+	GatewayDropPercentage  float64               `json:"gateway_drop_percentage"`
+	WeightedRate           float64               `json:"weighted_rate"`
+	PreviousWeightedRate   float64               `json:"previous_weighted_rate"`
+	WeightedDropPercentage float64               `json:"weighted_drop_percentage"`
+	LostGMV                float64               `json:"lost_gmv"`
+	Stage                  string                `json:"stage,omitempty"`
+	Funnel                 []models.FunnelStage  `json:"funnel,omitempty"`
+	ThresholdRule          string                `json:"threshold_rule,omitempty"`
+	Threshold              float64               `json:"threshold,omitempty"`
+	PValue                 float64               `json:"p_value"`
+	IntervalLow            float64               `json:"interval_low"`
+	IntervalHigh           float64               `json:"interval_high"`
+	CurrentTotal           int                   `json:"current_total"`
+	ExpectedTotal          float64               `json:"expected_total"`
+	VolumeDrop             float64               `json:"volume_drop"`
+	Latency                []models.LatencyStats `json:"latency,omitempty"`
+	Spike                  *models.RateSpike     `json:"spike,omitempty"`
+	Rule                   *models.RuleMatch     `json:"rule,omitempty"`
+	SLO                    *models.SLOBurn       `json:"slo,omitempty"`
+	Forecast               *models.Forecast      `json:"forecast,omitempty"`
+	Timestamp              time.Time             `json:"timestamp"`
+	StartedAt              time.Time             `json:"started_at"`
+	DrillDown              []models.Contribution `json:"drill_down,omitempty"`
+	Errors                 []models.ErrorCount   `json:"errors,omitempty"`
+	RootCause              string                `json:"root_cause,omitempty"`
+	Confidence             float64               `json:"confidence,omitempty"`
+	Recommendations        []string              `json:"recommendations,omitempty"`
+	RelatedChanges         []string              `json:"related_changes,omitempty"`
 }
 
 type Hub struct {
@@ -43,6 +81,9 @@ type Hub struct {
 	Register   chan *Client
 	Unregister chan *Client
 	mu         sync.Mutex
+
+	relay        *redis.Client // nil broadcasts to this hub's clients only
+	relayChannel string
 }
 
 func NewHub() *Hub {
@@ -89,7 +130,7 @@ func (h *Hub) BroadcastMetrics(metrics *MetricsMessage) {
 		log.Printf("Error marshaling metrics: %v", err)
 		return
 	}
-	h.Broadcast <- data
+	h.send(data)
 }
 
 func (h *Hub) BroadcastAlert(alert *AlertMessage) {
@@ -98,7 +139,7 @@ func (h *Hub) BroadcastAlert(alert *AlertMessage) {
 		log.Printf("Error marshaling alert: %v", err)
 		return
 	}
-	h.Broadcast <- data
+	h.send(data)
 }
 
 func (c *Client) WritePump() {
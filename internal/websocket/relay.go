@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-redis/redis"
+)
+
+// UseRelay makes the hub publish its broadcasts on a Redis channel instead of
+// sending them to its own clients. Every replica's hub forwards what is
+// published there to its clients with RunRelay, so that followers serve the
+// metrics and alerts of the leader.
+func (h *Hub) UseRelay(client *redis.Client, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.relay = client
+	h.relayChannel = channel
+}
+
+// RunRelay forwards the messages published on the relay channel to the
+// clients of this hub until ctx is done
+func (h *Hub) RunRelay(ctx context.Context) {
+	h.mu.Lock()
+	client, channel := h.relay, h.relayChannel
+	h.mu.Unlock()
+	if client == nil {
+		return
+	}
+
+	pubsub := client.Subscribe(channel)
+	defer pubsub.Close()
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			h.Broadcast <- []byte(message.Payload)
+		}
+	}
+}
+
+// send broadcasts data through the relay, or straight to the hub's own
+// clients when there is none or Redis cannot be reached
+func (h *Hub) send(data []byte) {
+	h.mu.Lock()
+	client, channel := h.relay, h.relayChannel
+	h.mu.Unlock()
+	if client != nil {
+		err := client.Publish(channel, data).Err()
+		if err == nil {
+			return
+		}
+		log.Printf("Error publishing to relay, broadcasting locally: %v", err)
+	}
+	h.Broadcast <- data
+}
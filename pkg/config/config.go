@@ -10,13 +10,88 @@ type Config struct {
 	Monitoring struct {
 		Interval   int `yaml:"interval"`
 		Thresholds struct {
-			SuccessRateDrop float64 `yaml:"success_rate_drop"`
-			MinTransactions int     `yaml:"minimum_transactions"`
+			SuccessRateDrop float64             `yaml:"success_rate_drop"`
+			MinTransactions int                 `yaml:"minimum_transactions"`
+			Basis           string              `yaml:"basis"`
+			LostGMV         float64             `yaml:"lost_gmv"`
+			Overrides       []ThresholdOverride `yaml:"overrides"`
 		} `yaml:"thresholds"`
-		Dimensions []struct {
-			Name    string `yaml:"name"`
-			Enabled bool   `yaml:"enabled"`
-		} `yaml:"dimensions"`
+		Windows struct {
+			CurrentMinutes  int `yaml:"current_minutes"`
+			BaselineMinutes int `yaml:"baseline_minutes"`
+		} `yaml:"windows"`
+		Baseline struct {
+			Strategy         string  `yaml:"strategy"`
+			Weeks            int     `yaml:"weeks"`
+			FallbackHours    int     `yaml:"fallback_hours"`
+			SuccessRateFloor float64 `yaml:"success_rate_floor"`
+		} `yaml:"baseline"`
+		Significance struct {
+			Method     string  `yaml:"method"`
+			Confidence float64 `yaml:"confidence"`
+		} `yaml:"significance"`
+		Alerting struct {
+			CooldownMinutes int `yaml:"cooldown_minutes"`
+			RenotifyMinutes int `yaml:"renotify_minutes"`
+			ExpireMinutes   int `yaml:"expire_minutes"`
+		} `yaml:"alerting"`
+		Rollup struct {
+			LatenessMinutes int `yaml:"lateness_minutes"`
+		} `yaml:"rollup"`
+		DrillDown struct {
+			Enabled  bool     `yaml:"enabled"`
+			Children []string `yaml:"children"`
+			TopN     int      `yaml:"top_n"`
+		} `yaml:"drill_down"`
+		Volume struct {
+			Enabled             bool    `yaml:"enabled"`
+			DropPercentage      float64 `yaml:"drop_percentage"`
+			MinimumTransactions int     `yaml:"minimum_baseline_transactions"`
+		} `yaml:"volume"`
+		Latency struct {
+			Enabled                bool    `yaml:"enabled"`
+			IntervalSeconds        int     `yaml:"interval_seconds"`
+			Percentile             int     `yaml:"percentile"`
+			IncreasePercentage     float64 `yaml:"increase_percentage"`
+			MinimumIncreaseSeconds float64 `yaml:"minimum_increase_seconds"`
+		} `yaml:"latency"`
+		Refunds struct {
+			Enabled             bool     `yaml:"enabled"`
+			IntervalSeconds     int      `yaml:"interval_seconds"`
+			Dimensions          []string `yaml:"dimensions"`
+			RefundRateIncrease  float64  `yaml:"refund_rate_increase"`
+			DisputeRateIncrease float64  `yaml:"dispute_rate_increase"`
+			MinimumTransactions int      `yaml:"minimum_captured_transactions"`
+		} `yaml:"refunds"`
+		Metrics struct {
+			MaxValuesPerDimension int `yaml:"max_values_per_dimension"`
+		} `yaml:"metrics"`
+		Errors struct {
+			TopN int `yaml:"top_n"`
+		} `yaml:"errors"`
+		Outcomes struct {
+			Rules   []OutcomeRule `yaml:"rules"`
+			Default string        `yaml:"default"`
+		} `yaml:"outcomes"`
+		Dimensions []DimensionConfig `yaml:"dimensions"`
+		Rules      []AlertRuleConfig `yaml:"rules"`
+		Detector   struct {
+			Type     string `yaml:"type"`
+			Forecast struct {
+				Timezone           string  `yaml:"timezone"`
+				Band               float64 `yaml:"band"`
+				WarmUpObservations int     `yaml:"warm_up_observations"`
+				LevelSmoothing     float64 `yaml:"level_smoothing"`
+				SeasonalSmoothing  float64 `yaml:"seasonal_smoothing"`
+			} `yaml:"forecast"`
+		} `yaml:"detector"`
+		SLO struct {
+			Enabled             bool               `yaml:"enabled"`
+			IntervalSeconds     int                `yaml:"interval_seconds"`
+			MinimumTransactions int                `yaml:"minimum_transactions"`
+			BurnWindows         []BurnWindowConfig `yaml:"burn_windows"`
+			Objectives          []ObjectiveConfig  `yaml:"objectives"`
+		} `yaml:"slo"`
 	} `yaml:"monitoring"`
 
 	Database struct {
@@ -57,6 +132,12 @@ type Config struct {
 		} `yaml:"experiments"`
 	} `yaml:"context_builder"`
 
+	LeaderElection struct {
+		Enabled    bool   `yaml:"enabled"`
+		Key        string `yaml:"key"`
+		TTLSeconds int    `yaml:"ttl_seconds"`
+	} `yaml:"leader_election"`
+
 	Redis struct {
 		Host     string `yaml:"host"`
 		Port     int    `yaml:"port"`
@@ -65,6 +146,81 @@ type Config struct {
 	} `yaml:"redis"`
 }
 
+// DimensionConfig declares a monitored dimension. Columns lists the payments
+// columns to group by; it may be omitted for the built-in dimensions and for
+// dimensions named after a single column. Fields adds values read from JSON
+// documents in payments. Values outside TopK or below MinVolume are folded
+// into a single "__other__" value.
+type DimensionConfig struct {
+	Name      string            `yaml:"name"`
+	Enabled   bool              `yaml:"enabled"`
+	Columns   []string          `yaml:"columns"`
+	Fields    []JSONFieldConfig `yaml:"fields"`
+	TopK      int               `yaml:"top_k"`          // monitor only the K values with the most payments
+	MinVolume int               `yaml:"minimum_volume"` // monitor only values with at least this many payments
+}
+
+// JSONFieldConfig declares a dimension field read from a path of a JSON
+// document, e.g. path card.network of acquirer_data
+type JSONFieldConfig struct {
+	Name    string `yaml:"name"`    // attribute name reported on stats and alerts
+	Column  string `yaml:"column"`  // acquirer_data, fee_data, error or a text column holding JSON, e.g. notes
+	Path    string `yaml:"path"`    // dot-separated keys; array elements by index
+	Type    string `yaml:"type"`    // string (default), number or boolean
+	Default string `yaml:"default"` // value when the path is missing, null or cannot be coerced
+}
+
+// AlertRuleConfig declares a named alert rule. Expr is a condition on the
+// stats of a dimension value, e.g. "success_rate < 85 && total > 200".
+type AlertRuleConfig struct {
+	Name     string            `yaml:"name"`
+	Expr     string            `yaml:"expr"`
+	Severity string            `yaml:"severity"` // info, warning (default) or critical
+	Labels   map[string]string `yaml:"labels"`   // copied onto the rule's alerts, e.g. team: payments
+}
+
+// ObjectiveConfig declares a success-rate objective for every value of a
+// dimension, or for those whose attributes match the glob patterns of Match
+type ObjectiveConfig struct {
+	Name       string            `yaml:"name"`
+	Dimension  string            `yaml:"dimension"`
+	Match      map[string]string `yaml:"match"`
+	Target     float64           `yaml:"target"`      // percent, e.g. 97
+	WindowDays int               `yaml:"window_days"` // defaults to 30
+	Basis      string            `yaml:"basis"`       // count (default) or gateway
+}
+
+// BurnWindowConfig declares a pair of windows an objective's error budget
+// must burn at least Factor times too fast over to alert
+type BurnWindowConfig struct {
+	Name         string  `yaml:"name"`
+	LongMinutes  int     `yaml:"long_minutes"`
+	ShortMinutes int     `yaml:"short_minutes"`
+	Factor       float64 `yaml:"factor"`
+}
+
+// ThresholdOverride replaces the global thresholds for the values it matches.
+// Match maps attribute names to glob patterns, e.g. {gateway: "hdfc*"}; all
+// of them must match and an empty Dimension matches any dimension. Unset
+// thresholds keep their global value.
+type ThresholdOverride struct {
+	Name            string            `yaml:"name"`
+	Dimension       string            `yaml:"dimension"`
+	Match           map[string]string `yaml:"match"`
+	SuccessRateDrop *float64          `yaml:"success_rate_drop"`
+	MinTransactions *int              `yaml:"minimum_transactions"`
+	LostGMV         *float64          `yaml:"lost_gmv"`
+}
+
+// OutcomeRule classifies the payments matching all of its non-empty lists as
+// success, gateway_failure, customer_drop_off or excluded
+type OutcomeRule struct {
+	Outcome  string   `yaml:"outcome"`
+	Statuses []string `yaml:"statuses"`
+	Codes    []string `yaml:"codes"`
+	Reasons  []string `yaml:"reasons"`
+}
+
 type ExperimentID struct {
 	ID          string `yaml:"id"`
 	Name        string `yaml:"name"`
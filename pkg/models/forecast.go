@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ForecastState is the model the forecast detector learned for one metric of
+// one dimension value, persisted so that it survives restarts
+type ForecastState struct {
+	Key       string `gorm:"primaryKey"` // metric, dimension and attributes
+	Dimension string `gorm:"index"`
+	Value     string
+	Metric    string
+	State     RawJSON
+	UpdatedAt time.Time
+}
+
+// TableName specifies the table name for ForecastState model
+func (ForecastState) TableName() string {
+	return "forecast_states"
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AlertRecord is the persisted form of an alert incident. The filterable
+// fields are kept in columns; the full alert is kept as a JSON snapshot.
+type AlertRecord struct {
+	ID             string    `gorm:"primaryKey"`
+	Type           string    `gorm:"index;not null"`
+	Fingerprint    string    `gorm:"index"`
+	Dimension      string    `gorm:"index"`
+	Value          string    `gorm:"index"`
+	State          string    `gorm:"index"`
+	StartedAt      time.Time `gorm:"index"`
+	LastNotifiedAt time.Time
+	ResolvedAt     *time.Time
+	Snapshot       RawJSON `gorm:"type:json"` // json keeps the attribute order
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// TableName specifies the table name for AlertRecord model
+func (AlertRecord) TableName() string {
+	return "alerts"
+}
+
+// NewAlertRecord snapshots an alert for persistence
+func NewAlertRecord(alert *Alert) (*AlertRecord, error) {
+	snapshot, err := json.Marshal(alert)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &AlertRecord{
+		ID:             alert.ID,
+		Type:           string(alert.Type),
+		Fingerprint:    alert.Fingerprint,
+		Dimension:      alert.Dimension,
+		Value:          alert.Value,
+		State:          string(alert.State),
+		StartedAt:      alert.StartedAt,
+		LastNotifiedAt: alert.LastNotifiedAt,
+		Snapshot:       snapshot,
+	}
+	if !alert.ResolvedAt.IsZero() {
+		resolvedAt := alert.ResolvedAt
+		record.ResolvedAt = &resolvedAt
+	}
+	return record, nil
+}
+
+// Alert decodes the alert snapshot of the record
+func (r *AlertRecord) Alert() (*Alert, error) {
+	var alert Alert
+	if err := json.Unmarshal(r.Snapshot, &alert); err != nil {
+		return nil, err
+	}
+	return &alert, nil
+}
+
+// AlertAnalysis is the persisted context snapshot and LLM result produced for
+// one notification of an alert
+type AlertAnalysis struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	AlertID   string    `gorm:"index" json:"alert_id"`
+	Context   RawJSON   `gorm:"type:json" json:"context"`
+	Result    RawJSON   `gorm:"type:json" json:"result"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for AlertAnalysis model
+func (AlertAnalysis) TableName() string {
+	return "alert_analyses"
+}
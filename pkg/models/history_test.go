@@ -0,0 +1,49 @@
+package models
+
+import (
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+func TestAlertRecordKeepsAttributeOrder(t *testing.T) {
+	// jsonb would return these sorted shortest key first: method, gateway
+	attrs := Attributes{
+		{Name: "gateway", Value: "hdfc"},
+		{Name: "method", Value: "card"},
+	}
+	record, err := NewAlertRecord(&Alert{ID: "a-1", Type: AlertTypeSuccessRate, Dimension: "gateway_method", Attributes: attrs})
+	if err != nil {
+		t.Fatalf("NewAlertRecord failed: %v", err)
+	}
+
+	s, err := schema.Parse(&AlertRecord{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("parsing the AlertRecord schema failed: %v", err)
+	}
+	if got := s.LookUpField("Snapshot").DataType; got != "json" {
+		t.Errorf("snapshot column is %s, want json so that the stored key order is kept", got)
+	}
+
+	// Store and load the snapshot as the database driver would
+	stored, err := record.Snapshot.Value()
+	if err != nil {
+		t.Fatalf("Value failed: %v", err)
+	}
+	var loaded AlertRecord
+	if err := loaded.Snapshot.Scan([]byte(stored.(string))); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	alert, err := loaded.Alert()
+	if err != nil {
+		t.Fatalf("Alert failed: %v", err)
+	}
+
+	if got, want := alert.Attributes.Key(), attrs.Key(); got != want {
+		t.Errorf("Key() = %s after a round trip, want %s", got, want)
+	}
+	if got, want := alert.Attributes.Label(), attrs.Label(); got != want {
+		t.Errorf("Label() = %s after a round trip, want %s", got, want)
+	}
+}
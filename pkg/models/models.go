@@ -1,66 +1,410 @@
 package models
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
+// Attribute is a single dimension column and its value, e.g. gateway=hdfc
+type Attribute struct {
+	Name  string
+	Value string
+}
+
+// Attributes is the ordered set of attributes identifying a dimension value.
+// It marshals to a JSON object whose keys keep the dimension's field order.
+type Attributes []Attribute
+
+// Get returns the value of the named attribute, or "" if it is not present
+func (a Attributes) Get(name string) string {
+	for _, attr := range a {
+		if attr.Name == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Label returns the attribute values joined for display, e.g. "hdfc/card".
+// It is not meant to be parsed back; use the attributes themselves instead.
+func (a Attributes) Label() string {
+	values := make([]string, len(a))
+	for i, attr := range a {
+		values[i] = attr.Value
+	}
+	return strings.Join(values, "/")
+}
+
+// Key returns an unambiguous identity for the attributes, safe to use as a
+// map key regardless of the characters the values contain
+func (a Attributes) Key() string {
+	data, _ := json.Marshal(a)
+	return string(data)
+}
+
+// String formats the attributes as "gateway=hdfc, method=card"
+func (a Attributes) String() string {
+	parts := make([]string, len(a))
+	for i, attr := range a {
+		parts[i] = attr.Name + "=" + attr.Value
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MarshalJSON encodes the attributes as an object, preserving their order
+func (a Attributes) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, attr := range a {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(attr.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(attr.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes an object of string values, preserving key order
+func (a *Attributes) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*a = nil
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("attributes must be a JSON object")
+	}
+
+	attrs := Attributes{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, _ := tok.(string)
+		var value string
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("attribute %q: %v", name, err)
+		}
+		attrs = append(attrs, Attribute{Name: name, Value: value})
+	}
+	*a = attrs
+	return nil
+}
+
 // PaymentStats represents the statistics for a specific dimension
 type PaymentStats struct {
-	Dimension      string
-	Value          string
-	Total          int
-	Successful     int
-	SuccessRate    float64
-	PreviousRate   float64
-	DropPercentage float64
-	Timestamp      time.Time
+	Dimension               string        `json:"dimension"`
+	Value                   string        `json:"value"`
+	Attributes              Attributes    `json:"attributes"`
+	Total                   int           `json:"total"`
+	Successful              int           `json:"successful"`
+	SuccessRate             float64       `json:"success_rate"`
+	PreviousTotal           int           `json:"previous_total"`
+	PreviousSuccessful      int           `json:"previous_successful"`
+	PreviousRate            float64       `json:"previous_rate"`
+	Baseline                string        `json:"baseline"`         // strategy PreviousRate was computed with
+	CustomerDropped         int           `json:"customer_dropped"` // payments abandoned or declined by the customer
+	PreviousCustomerDropped int           `json:"previous_customer_dropped"`
+	GatewayRate             float64       `json:"gateway_rate"` // success rate leaving out customer drop-offs
+	PreviousGatewayRate     float64       `json:"previous_gateway_rate"`
+	GatewayDropPercentage   float64       `json:"gateway_drop_percentage"`
+	HasBaseline             bool          `json:"has_baseline"`
+	NewValue                bool          `json:"new_value"` // no data in the baseline windows
+	DropPercentage          float64       `json:"drop_percentage"`
+	TotalAmount             int64         `json:"total_amount"` // base amount in minor units
+	SuccessfulAmount        int64         `json:"successful_amount"`
+	WeightedRate            float64       `json:"weighted_rate"` // amount-weighted success rate
+	PreviousWeightedRate    float64       `json:"previous_weighted_rate"`
+	WeightedDropPercentage  float64       `json:"weighted_drop_percentage"`
+	LostGMV                 float64       `json:"lost_gmv"` // successful amount short of the baseline weighted rate
+	Funnel                  []FunnelStage `json:"funnel,omitempty"`
+	Stage                   string        `json:"stage,omitempty"` // funnel stage whose conversion dropped the most
+	PValue                  float64       `json:"p_value"`         // one-sided p-value of the drop
+	IntervalLow             float64       `json:"interval_low"`    // confidence interval of the compared rate
+	IntervalHigh            float64       `json:"interval_high"`
+	ExpectedTotal           float64       `json:"expected_total,omitempty"` // baseline volume scaled to the current window
+	VolumeDrop              float64       `json:"volume_drop,omitempty"`    // percent below ExpectedTotal
+	Timestamp               time.Time     `json:"timestamp"`
+}
+
+// AlertState is the lifecycle state of an alert
+type AlertState string
+
+const (
+	AlertStateFiring       AlertState = "firing"
+	AlertStateAcknowledged AlertState = "acknowledged"
+	AlertStateResolved     AlertState = "resolved"
+)
+
+// AlertType is the kind of anomaly an alert reports
+type AlertType string
+
+const (
+	// AlertTypeSuccessRate is a drop in success rate compared with the baseline
+	AlertTypeSuccessRate AlertType = "success_rate"
+	// AlertTypeVolumeDrop is a drop in payment volume compared with the baseline
+	AlertTypeVolumeDrop AlertType = "volume_drop"
+	// AlertTypeSilent is a value that had steady volume and now has none
+	AlertTypeSilent AlertType = "silent"
+	// AlertTypeLatency is a regression of authorization or capture latency
+	AlertTypeLatency AlertType = "latency"
+	// AlertTypeRefundRate is a spike in the share of captured payments refunded
+	AlertTypeRefundRate AlertType = "refund_rate"
+	// AlertTypeDisputeRate is a spike in the share of captured payments disputed
+	AlertTypeDisputeRate AlertType = "dispute_rate"
+	// AlertTypeRule is a dimension value matching a configured alert rule
+	AlertTypeRule AlertType = "rule"
+	// AlertTypeSLOBurn is a dimension value burning the error budget of a
+	// success-rate objective too fast
+	AlertTypeSLOBurn AlertType = "slo_burn"
+)
+
+// family groups the alert types that describe the same incident. A value
+// whose volume drops and then stops entirely is one volume incident.
+func (t AlertType) family() AlertType {
+	switch t {
+	case AlertTypeSilent:
+		return AlertTypeVolumeDrop
+	default:
+		return t
+	}
+}
+
+// AlertFingerprint identifies the anomaly and dimension value an alert is
+// about, so that repeated breaches of the same value belong to the same
+// incident.
+func AlertFingerprint(alertType AlertType, dimension string, attrs Attributes) string {
+	key := string(alertType.family()) + "\x00" + dimension + "\x00" + attrs.Key()
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// RuleFingerprint identifies the dimension value an alert rule fired for.
+// Each rule is an incident of its own.
+func RuleFingerprint(rule, dimension string, attrs Attributes) string {
+	return AlertFingerprint(AlertTypeRule+AlertType(":"+rule), dimension, attrs)
+}
+
+// SLOFingerprint identifies the dimension value an objective burns too fast
+// over a burn window. Fast and slow burns are incidents of their own.
+func SLOFingerprint(objective, window, dimension string, attrs Attributes) string {
+	return AlertFingerprint(AlertTypeSLOBurn+AlertType(":"+objective+":"+window), dimension, attrs)
 }
 
 // Alert represents an alert generated when success rate drops
 type Alert struct {
-	ID              string
-	Dimension       string
-	Value           string
-	CurrentRate     float64
-	PreviousRate    float64
-	DropPercentage  float64
-	Timestamp       time.Time
-	Context         *AnalysisContext
-	Gateway         string
-	Method          string
-	MerchantID      string
-	RootCause       string
-	Confidence      float64
-	Recommendations []string
+	ID                     string           `json:"id"`
+	Type                   AlertType        `json:"type"`
+	Fingerprint            string           `json:"fingerprint"`
+	State                  AlertState       `json:"state"`
+	StartedAt              time.Time        `json:"started_at"`
+	LastNotifiedAt         time.Time        `json:"last_notified_at"`
+	AcknowledgedAt         time.Time        `json:"acknowledged_at"`
+	ResolvedAt             time.Time        `json:"resolved_at"`
+	Dimension              string           `json:"dimension"`
+	Value                  string           `json:"value"`
+	Attributes             Attributes       `json:"attributes"`
+	CurrentRate            float64          `json:"current_rate"`
+	PreviousRate           float64          `json:"previous_rate"`
+	Baseline               string           `json:"baseline"`
+	HasBaseline            bool             `json:"has_baseline"` // false when nothing was there to compare with
+	NewValue               bool             `json:"new_value"`    // compared against a fallback, not the baseline windows
+	DropPercentage         float64          `json:"drop_percentage"`
+	CustomerDropped        int              `json:"customer_dropped"`
+	GatewayRate            float64          `json:"gateway_rate"` // success rate leaving out customer drop-offs
+	PreviousGatewayRate    float64          `json:"previous_gateway_rate"`
+	GatewayDropPercentage  float64          `json:"gateway_drop_percentage"`
+	PValue                 float64          `json:"p_value"`
+	IntervalLow            float64          `json:"interval_low"`
+	IntervalHigh           float64          `json:"interval_high"`
+	CurrentTotal           int              `json:"current_total"`
+	WeightedRate           float64          `json:"weighted_rate"`
+	PreviousWeightedRate   float64          `json:"previous_weighted_rate"`
+	WeightedDropPercentage float64          `json:"weighted_drop_percentage"`
+	LostGMV                float64          `json:"lost_gmv"`        // minor units of the base currency
+	Stage                  string           `json:"stage,omitempty"` // funnel stage that regressed
+	Funnel                 []FunnelStage    `json:"funnel,omitempty"`
+	ThresholdRule          string           `json:"threshold_rule,omitempty"` // threshold rule the alert was raised under
+	Threshold              float64          `json:"threshold,omitempty"`
+	ExpectedTotal          float64          `json:"expected_total"` // baseline volume scaled to the current window
+	VolumeDrop             float64          `json:"volume_drop"`    // percent below the expected volume
+	Latency                []LatencyStats   `json:"latency,omitempty"`
+	Spike                  *RateSpike       `json:"spike,omitempty"`    // refund and dispute alerts
+	Rule                   *RuleMatch       `json:"rule,omitempty"`     // rule alerts
+	SLO                    *SLOBurn         `json:"slo,omitempty"`      // SLO burn alerts
+	Forecast               *Forecast        `json:"forecast,omitempty"` // alerts of the forecast detector
+	Timestamp              time.Time        `json:"timestamp"`
+	DrillDown              []Contribution   `json:"drill_down,omitempty"`
+	Errors                 []ErrorCount     `json:"errors,omitempty"`
+	Context                *AnalysisContext `json:"context,omitempty"`
+	Gateway                string           `json:"gateway,omitempty"`
+	Method                 string           `json:"method,omitempty"`
+	MerchantID             string           `json:"merchant_id,omitempty"`
+	RootCause              string           `json:"root_cause,omitempty"`
+	Confidence             float64          `json:"confidence,omitempty"`
+	Recommendations        []string         `json:"recommendations,omitempty"`
+}
+
+// RuleMatch is the alert rule an alert fired under
+type RuleMatch struct {
+	Name       string            `json:"name"`
+	Expression string            `json:"expression"`
+	Severity   string            `json:"severity"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// SLOBurn is how fast a dimension value burns the error budget of a
+// success-rate objective, over the long and short window of a burn window.
+// Burn rates are the error rate over the one the target allows.
+type SLOBurn struct {
+	Objective       string  `json:"objective"`
+	Target          float64 `json:"target"` // percent
+	Window          string  `json:"window"` // burn window name, e.g. fast
+	LongWindow      string  `json:"long_window"`
+	ShortWindow     string  `json:"short_window"`
+	Factor          float64 `json:"factor"` // burn rate both windows must reach
+	LongBurnRate    float64 `json:"long_burn_rate"`
+	ShortBurnRate   float64 `json:"short_burn_rate"`
+	LongTotal       int     `json:"long_total"`
+	ShortTotal      int     `json:"short_total"`
+	SuccessRate     float64 `json:"success_rate"`     // over the long window
+	BudgetRemaining float64 `json:"budget_remaining"` // percent of the objective window's budget left
+	Burning         bool    `json:"burning"`
+}
+
+// Forecast is what the forecast detector expected of a metric of a dimension
+// value at this time of day and week, and the band it learned around it
+type Forecast struct {
+	Metric    string  `json:"metric"` // success_rate or volume
+	Actual    float64 `json:"actual"`
+	Expected  float64 `json:"expected"`
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+	Deviation float64 `json:"deviation"` // residual in standard deviations
+}
+
+// FunnelStage is the conversion of one payment stage: the share of the
+// payments that entered the stage and passed it
+type FunnelStage struct {
+	Stage              string  `json:"stage"` // authentication, authorization or capture
+	Entered            int     `json:"entered"`
+	Passed             int     `json:"passed"`
+	Conversion         float64 `json:"conversion"`
+	BaselineConversion float64 `json:"baseline_conversion"`
+	Drop               float64 `json:"drop"` // percentage points below the baseline conversion
+}
+
+// Contribution is how much one child attribute value explains of an alert's
+// drop, e.g. method=upi under a gateway alert
+type Contribution struct {
+	Attribute      string  `json:"attribute"`
+	Value          string  `json:"value"`
+	Total          int     `json:"total"`
+	SuccessRate    float64 `json:"success_rate"`
+	BaselineRate   float64 `json:"baseline_rate"`
+	ExcessFailures float64 `json:"excess_failures"` // failures beyond what the baseline rate predicts
+	Share          float64 `json:"share"`           // percent of the attribute's excess failures
+}
+
+// LatencyStats holds the latency percentiles of one payment stage, in seconds,
+// in the current window and the baseline
+type LatencyStats struct {
+	Metric             string  `json:"metric"` // "authorization" or "capture"
+	Count              int     `json:"count"`
+	BaselineCount      int     `json:"baseline_count"`
+	P50                float64 `json:"p50"`
+	P95                float64 `json:"p95"`
+	P99                float64 `json:"p99"`
+	BaselineP50        float64 `json:"baseline_p50"`
+	BaselineP95        float64 `json:"baseline_p95"`
+	BaselineP99        float64 `json:"baseline_p99"`
+	Percentile         int     `json:"percentile"`          // the percentile compared
+	Increase           float64 `json:"increase"`            // seconds over the baseline percentile
+	IncreasePercentage float64 `json:"increase_percentage"` // 0 when the baseline percentile is 0
+	Regressed          bool    `json:"regressed"`
+}
+
+// RateSpike is the rate of refunds or disputes among captured payments, in
+// the current window and the baseline
+type RateSpike struct {
+	Metric           string  `json:"metric"` // "refund" or "dispute"
+	Events           int     `json:"events"`
+	Captured         int     `json:"captured"`
+	Rate             float64 `json:"rate"` // events per captured payment, in percent
+	BaselineEvents   int     `json:"baseline_events"`
+	BaselineCaptured int     `json:"baseline_captured"`
+	BaselineRate     float64 `json:"baseline_rate"`
+	Increase         float64 `json:"increase"` // percentage points over the baseline rate
+}
+
+// ErrorCount is how often one error code or reason occurred among the failed
+// payments of an alert, now and in the baseline
+type ErrorCount struct {
+	Field         string  `json:"field"` // "code" or "reason"
+	Value         string  `json:"value"`
+	Current       int     `json:"current"`
+	Baseline      int     `json:"baseline"`
+	CurrentShare  float64 `json:"current_share"` // percent of the current failures
+	BaselineShare float64 `json:"baseline_share"`
+	New           bool    `json:"new"` // did not occur in the baseline
 }
 
 // AnalysisContext contains all the context data for LLM analysis
 type AnalysisContext struct {
-	PaymentStats  *PaymentStats
-	RecentChanges []GitHubChange
-	LogEntries    []LogEntry
-	Experiments   []ExperimentPair
+	AlertType     AlertType        `json:"alert_type"`
+	ThresholdRule string           `json:"threshold_rule,omitempty"`
+	Threshold     float64          `json:"threshold,omitempty"`
+	PaymentStats  *PaymentStats    `json:"payment_stats"`
+	DrillDown     []Contribution   `json:"drill_down,omitempty"`
+	Errors        []ErrorCount     `json:"errors,omitempty"`
+	Latency       []LatencyStats   `json:"latency,omitempty"`
+	Spike         *RateSpike       `json:"spike,omitempty"`
+	Rule          *RuleMatch       `json:"rule,omitempty"`
+	SLO           *SLOBurn         `json:"slo,omitempty"`
+	Forecast      *Forecast        `json:"forecast,omitempty"`
+	RecentChanges []GitHubChange   `json:"recent_changes"`
+	LogEntries    []LogEntry       `json:"log_entries"`
+	Experiments   []ExperimentPair `json:"experiments"`
 }
 
 // GitHubChange represents a code change from GitHub
 type GitHubChange struct {
-	Repo         string
-	CommitID     string
-	Author       string
-	Message      string
-	Timestamp    time.Time
-	FilesChanged []string
+	Repo         string    `json:"repo"`
+	CommitID     string    `json:"commit_id"`
+	Author       string    `json:"author"`
+	Message      string    `json:"message"`
+	Timestamp    time.Time `json:"timestamp"`
+	FilesChanged []string  `json:"files_changed"`
 }
 
 // LogEntry represents a log entry from payment system
 type LogEntry struct {
-	Timestamp time.Time
-	Level     string
-	Message   string
-	Context   map[string]interface{}
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Context   map[string]interface{} `json:"context"`
 }
 
 // Experiment represents an active A/B experiment
@@ -93,6 +437,7 @@ type Payment struct {
 	ID                string `gorm:"primaryKey"`
 	MerchantID        string `gorm:"index"`
 	PaymentID         string `gorm:"index"`
+	CreatedAt         int64  // unix seconds, indexed by a concurrent build at startup
 	Amount            int64
 	Currency          string
 	Status            string
@@ -164,3 +509,51 @@ func (j JSONB) Value() (driver.Value, error) {
 func (JSONB) GormDataType() string {
 	return "jsonb"
 }
+
+// RawJSON is a column holding an encoded document, handed to and from the
+// database unchanged. It is JSONB by default, which re-sorts object keys;
+// columns whose key order has to survive, such as alert snapshots with their
+// attributes, are declared with type json, which stores the text verbatim.
+type RawJSON []byte
+
+// Scan implements the sql.Scanner interface for RawJSON
+func (j *RawJSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(RawJSON{}, v...)
+	case string:
+		*j = RawJSON(v)
+	default:
+		return fmt.Errorf("failed to scan RawJSON value: %v", value)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface for RawJSON
+func (j RawJSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// MarshalJSON embeds the stored document as is
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON keeps a copy of the encoded document
+func (j *RawJSON) UnmarshalJSON(data []byte) error {
+	*j = append(RawJSON{}, data...)
+	return nil
+}
+
+// GormDataType implements the GORM interface for RawJSON
+func (RawJSON) GormDataType() string {
+	return "jsonb"
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// PaymentStatsMinutely is one minute of payments for one combination of
+// dimension attributes. Layout identifies the set of attributes rows were
+// rolled up by, so that a dimension config change starts a fresh rollup.
+type PaymentStatsMinutely struct {
+	Layout           string  `gorm:"primaryKey"`
+	Bucket           int64   `gorm:"primaryKey;autoIncrement:false"` // unix seconds of the start of the minute
+	Attributes       RawJSON `gorm:"primaryKey"`
+	Total            int64   // payments that are not excluded by the outcome taxonomy
+	Successful       int64
+	CustomerDropped  int64
+	Excluded         int64
+	Authenticated    int64 // funnel stages reached, counted like Total
+	Authorized       int64
+	Captured         int64
+	TotalAmount      int64 // in base currency
+	SuccessfulAmount int64
+}
+
+// TableName specifies the table name for PaymentStatsMinutely model
+func (PaymentStatsMinutely) TableName() string {
+	return "payment_stats_minutely"
+}
+
+// RollupWatermark is the high-water mark on payments.created_at up to which a
+// rollup layout is complete
+type RollupWatermark struct {
+	Layout    string `gorm:"primaryKey"`
+	Watermark int64
+	UpdatedAt time.Time
+}
+
+// TableName specifies the table name for RollupWatermark model
+func (RollupWatermark) TableName() string {
+	return "rollup_watermarks"
+}
@@ -14,6 +14,15 @@ import {
 import ExpandMoreIcon from '@mui/icons-material/ExpandMore';
 import { format } from 'date-fns';
 
+// Success-rate drops against the baseline, and rule matches that have one, are
+// tested with a p-value and a confidence interval; forecast drops are not
+const testsDrop = (alert) =>
+  (alert.alert_type === 'success_rate' && !alert.forecast) || (alert.alert_type === 'rule' && alert.has_baseline);
+
+// Alert types whose anomaly is tested for significance
+const hasPValue = (alert) =>
+  testsDrop(alert) || ['volume_drop', 'silent', 'refund_rate', 'dispute_rate'].includes(alert.alert_type);
+
 function AlertsList({ alerts }) {
   if (alerts.length === 0) {
     return (
@@ -52,12 +61,54 @@ function AlertsList({ alerts }) {
                         word.charAt(0).toUpperCase() + word.slice(1)
                       ).join(' ')}
                     </Typography>
-                    <Chip
-                      label={alert.value}
-                      size="small"
-                      color="primary"
-                      sx={{ ml: 1 }}
-                    />
+                    {alert.attributes && Object.keys(alert.attributes).length > 0 ? (
+                      Object.entries(alert.attributes).map(([name, value]) => (
+                        <Chip
+                          key={name}
+                          label={`${name}: ${value}`}
+                          size="small"
+                          color="primary"
+                          sx={{ ml: 1 }}
+                        />
+                      ))
+                    ) : (
+                      <Chip
+                        label={alert.value}
+                        size="small"
+                        color="primary"
+                        sx={{ ml: 1 }}
+                      />
+                    )}
+                    {alert.alert_type && alert.alert_type !== 'success_rate' && (
+                      <Chip
+                        label={alert.alert_type.replace('_', ' ')}
+                        size="small"
+                        color="warning"
+                        sx={{ ml: 1 }}
+                      />
+                    )}
+                    {alert.rule && (
+                      <Chip
+                        label={`rule: ${alert.rule.name}`}
+                        size="small"
+                        color={alert.rule.severity === 'critical' ? 'error' : alert.rule.severity === 'info' ? 'info' : 'warning'}
+                        sx={{ ml: 1 }}
+                      />
+                    )}
+                    {alert.stage && (
+                      <Chip label={`stage: ${alert.stage}`} size="small" color="secondary" sx={{ ml: 1 }} />
+                    )}
+                    {alert.new_value && (
+                      <Chip label="new value" size="small" color="info" sx={{ ml: 1 }} />
+                    )}
+                    {alert.state && (
+                      <Chip
+                        label={alert.state}
+                        size="small"
+                        color={alert.state === 'resolved' ? 'success' : alert.state === 'acknowledged' ? 'default' : 'error'}
+                        sx={{ ml: 1 }}
+                      />
+                    )}
                   </Box>
                 }
                 secondary={
@@ -68,7 +119,7 @@ function AlertsList({ alerts }) {
                       color="text.primary"
                       sx={{ display: 'block', mb: 0.5 }}
                     >
-                      Success Rate: {alert.current_rate.toFixed(2)}% (Previous: {alert.previous_rate.toFixed(2)}%)
+                      Success Rate: {alert.current_rate.toFixed(2)}% (Previous: {alert.previous_rate.toFixed(2)}%{alert.baseline ? `, ${alert.baseline}` : ''})
                     </Typography>
                     <Typography
                       component="span"
@@ -76,8 +127,59 @@ function AlertsList({ alerts }) {
                       color="error"
                       sx={{ display: 'block', mb: 0.5 }}
                     >
-                      Drop: {alert.drop_percentage.toFixed(2)}%
+                      {alert.alert_type === 'volume_drop' || alert.alert_type === 'silent'
+                        ? `Volume: ${alert.current_total} payments (expected ${alert.expected_total.toFixed(1)}, ${alert.volume_drop.toFixed(2)}% below)`
+                        : (alert.alert_type === 'refund_rate' || alert.alert_type === 'dispute_rate') && alert.spike
+                        ? `${alert.spike.metric === 'refund' ? 'Refund' : 'Dispute'} rate: ${alert.spike.rate.toFixed(2)}% of ${alert.spike.captured} captured (baseline ${alert.spike.baseline_rate.toFixed(2)}%, up ${alert.spike.increase.toFixed(2)} points)`
+                        : alert.alert_type === 'slo_burn' && alert.slo
+                        ? `SLO ${alert.slo.objective} (${alert.slo.target}%): ${alert.slo.window} burn ${alert.slo.long_burn_rate.toFixed(1)}x over ${alert.slo.long_window}, ${alert.slo.short_burn_rate.toFixed(1)}x over ${alert.slo.short_window}; ${alert.slo.budget_remaining.toFixed(1)}% of budget left`
+                        : alert.alert_type === 'latency'
+                        ? (alert.latency || []).filter(l => l.regressed).map(l =>
+                            `Latency: ${l.metric} p${l.percentile} up ${l.increase.toFixed(1)}s`).join(', ')
+                        : `Drop: ${alert.drop_percentage.toFixed(2)}% (gateway: ${(alert.gateway_drop_percentage || 0).toFixed(2)}%, weighted: ${(alert.weighted_drop_percentage || 0).toFixed(2)}%, lost GMV: ${((alert.lost_gmv || 0) / 100).toFixed(2)})`}
                     </Typography>
+                    {hasPValue(alert) && alert.p_value !== undefined && (
+                      <Typography
+                        component="span"
+                        variant="body2"
+                        color="text.secondary"
+                        sx={{ display: 'block', mb: 0.5 }}
+                      >
+                        p-value: {alert.p_value.toFixed(4)}
+                        {testsDrop(alert) && ` (CI: ${alert.interval_low.toFixed(2)}% - ${alert.interval_high.toFixed(2)}%)`}
+                      </Typography>
+                    )}
+                    {alert.rule && (
+                      <Typography
+                        component="span"
+                        variant="body2"
+                        color="text.secondary"
+                        sx={{ display: 'block', mb: 0.5 }}
+                      >
+                        Rule: {alert.rule.expression}
+                        {alert.rule.labels && Object.entries(alert.rule.labels).map(([name, value]) => ` ${name}=${value}`).join('')}
+                      </Typography>
+                    )}
+                    {alert.forecast && (
+                      <Typography
+                        component="span"
+                        variant="body2"
+                        color="text.secondary"
+                        sx={{ display: 'block', mb: 0.5 }}
+                      >
+                        Forecast: {alert.forecast.metric.replace('_', ' ')} {alert.forecast.actual.toFixed(2)} vs expected {alert.forecast.expected.toFixed(2)} (band {alert.forecast.lower.toFixed(2)} - {alert.forecast.upper.toFixed(2)}, {alert.forecast.deviation.toFixed(1)}σ)
+                      </Typography>
+                    )}
+                    {alert.threshold_rule && (
+                      <Typography
+                        component="span"
+                        variant="body2"
+                        color="text.secondary"
+                        sx={{ display: 'block', mb: 0.5 }}
+                      >
+                        Threshold: {alert.threshold} (rule: {alert.threshold_rule})
+                      </Typography>
+                    )}
                     <Typography
                       component="span"
                       variant="caption"
@@ -89,7 +191,7 @@ function AlertsList({ alerts }) {
                 }
               />
 
-              {(alert.root_cause || alert.recommendations?.length > 0 || alert.related_changes?.length > 0) && (
+              {(alert.root_cause || alert.recommendations?.length > 0 || alert.related_changes?.length > 0 || alert.drill_down?.length > 0 || alert.errors?.length > 0 || alert.funnel?.length > 0) && (
                 <Accordion sx={{ width: '100%', boxShadow: 'none', '&:before': { display: 'none' }, borderTop: '1px solid rgba(0, 0, 0, 0.12)' }}>
                   <AccordionSummary
                     expandIcon={<ExpandMoreIcon />}
@@ -103,6 +205,49 @@ function AlertsList({ alerts }) {
                     )}
                   </AccordionSummary>
                   <AccordionDetails sx={{ pt: 0 }}>
+                    {alert.funnel && alert.funnel.length > 0 && (
+                      <Box sx={{ mb: 1 }}>
+                        <Typography variant="subtitle2">Funnel:</Typography>
+                        <List dense disablePadding sx={{ pl: 2 }}>
+                          {alert.funnel.map((s) => (
+                            <ListItem key={s.stage} disableGutters sx={{ p: 0 }}>
+                              <Typography variant="body2" color={s.stage === alert.stage ? 'error' : 'inherit'}>
+                                - {s.stage}: {s.conversion.toFixed(2)}% ({s.passed}/{s.entered}) vs {s.baseline_conversion.toFixed(2)}%
+                              </Typography>
+                            </ListItem>
+                          ))}
+                        </List>
+                      </Box>
+                    )}
+                    {alert.drill_down && alert.drill_down.length > 0 && (
+                      <Box sx={{ mb: 1 }}>
+                        <Typography variant="subtitle2">Drill-Down:</Typography>
+                        <List dense disablePadding sx={{ pl: 2 }}>
+                          {alert.drill_down.map((c, i) => (
+                            <ListItem key={i} disableGutters sx={{ p: 0 }}>
+                              <Typography variant="body2">
+                                - {c.attribute}={c.value}: {c.share.toFixed(1)}% of excess failures ({c.success_rate.toFixed(2)}% vs {c.baseline_rate.toFixed(2)}%)
+                              </Typography>
+                            </ListItem>
+                          ))}
+                        </List>
+                      </Box>
+                    )}
+                    {alert.errors && alert.errors.length > 0 && (
+                      <Box sx={{ mb: 1 }}>
+                        <Typography variant="subtitle2">Errors:</Typography>
+                        <List dense disablePadding sx={{ pl: 2 }}>
+                          {alert.errors.map((e, i) => (
+                            <ListItem key={i} disableGutters sx={{ p: 0 }}>
+                              <Typography variant="body2">
+                                - {e.field} {e.value}: {e.current} ({e.current_share.toFixed(1)}%) vs {e.baseline} ({e.baseline_share.toFixed(1)}%)
+                              </Typography>
+                              {e.new && <Chip label="new" size="small" color="error" sx={{ ml: 1 }} />}
+                            </ListItem>
+                          ))}
+                        </List>
+                      </Box>
+                    )}
                     {alert.root_cause && (
                       <Box sx={{ mb: 1 }}>
                         <Typography variant="subtitle2">Root Cause:</Typography>
@@ -42,6 +42,22 @@ function MetricsChart({ data, dimension }) {
   // Get unique values (e.g., gateway names)
   const values = [...new Set(data.map(item => item.value))];
 
+  // Values whose latest point had nothing to compare with
+  const latest = data.reduce((acc, item) => ({ ...acc, [item.value]: item }), {});
+  const noBaseline = new Set(values.filter(value => latest[value].has_baseline === false));
+  // Values compared against the fallback because the baseline windows had no data
+  const newValues = new Set(values.filter(value => latest[value].new_value));
+  const lineName = (value) => {
+    if (noBaseline.has(value)) return `${value} (no baseline)`;
+    if (newValues.has(value)) return `${value} (new value)`;
+    return value;
+  };
+  const lineDash = (value) => {
+    if (noBaseline.has(value)) return '5 5';
+    if (newValues.has(value)) return '2 2';
+    return undefined;
+  };
+
   if (data.length === 0) {
     return (
       <Box sx={{ height: '100%', display: 'flex', alignItems: 'center', justifyContent: 'center' }}>
@@ -87,8 +103,9 @@ function MetricsChart({ data, dimension }) {
               key={value}
               type="monotone"
               dataKey={value}
-              name={value}
+              name={lineName(value)}
               stroke={COLORS[index % COLORS.length]}
+              strokeDasharray={lineDash(value)}
               strokeWidth={2}
               dot={false}
             />
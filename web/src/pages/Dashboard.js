@@ -28,7 +28,8 @@ function Dashboard() {
         });
       } else if (data.type === 'alert') {
         console.log('Alert received:', data);
-        setAlerts(prevAlerts => [data, ...prevAlerts].slice(0, 10));
+        // Re-notifications and resolutions reuse the incident id
+        setAlerts(prevAlerts => [data, ...prevAlerts.filter(a => a.id !== data.id)].slice(0, 10));
       }
     };
 